@@ -0,0 +1,489 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kevhlee/glox/internal/lineedit"
+	"github.com/kevhlee/glox/pkg/ast"
+	"github.com/kevhlee/glox/pkg/compiler"
+	"github.com/kevhlee/glox/pkg/lox"
+	"github.com/kevhlee/glox/pkg/parser"
+	"github.com/kevhlee/glox/pkg/scanner"
+	"github.com/kevhlee/glox/pkg/token"
+	"github.com/kevhlee/glox/pkg/vm"
+)
+
+// Creates the flag set of a command with usage text describing it.
+func newFlagSet(cmd *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: glox %s [flags] %s\n\n%s.\n", cmd.name, cmd.args, cmd.summary)
+
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintf(fs.Output(), "\nFlags:\n")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// Parses the arguments of a command, checking that there are between minArgs
+// and maxArgs positional arguments (a negative maxArgs means no maximum).
+//
+// If the command should not continue, this function returns false along with
+// the exit status code.
+func parseArgs(fs *flag.FlagSet, args []string, minArgs, maxArgs int) (int, bool) {
+	if err := parseFlags(fs, args, maxArgs >= 0); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return lox.ExitOK, false
+		}
+		return exitUsage, false
+	}
+
+	if n := fs.NArg(); n < minArgs || (maxArgs >= 0 && n > maxArgs) {
+		fmt.Fprintf(fs.Output(), "glox %s: wrong number of arguments\n", fs.Name())
+		fs.Usage()
+		return exitUsage, false
+	}
+
+	return lox.ExitOK, true
+}
+
+// Adds the flag selecting the format of reported errors to a command.
+func diagnosticsFlag(fs *flag.FlagSet) *string {
+	return fs.String("diagnostics", "text", "format of reported errors (text or json)")
+}
+
+// Adds the flag selecting the backend that executes programs to a command.
+func backendFlag(fs *flag.FlagSet) *string {
+	return fs.String("backend", "tree", "backend that executes programs (tree or vm)")
+}
+
+// The default of the flag limiting allocations, in megabytes. Without a limit,
+// a runaway program may use all the memory of the machine before it is killed.
+const defaultAllocLimit = 1024
+
+// Adds the flag limiting the total size of allocations, in megabytes, to a
+// command.
+func allocLimitFlag(fs *flag.FlagSet) *int {
+	return fs.Int("alloc-limit", defaultAllocLimit, "approximate megabytes a program may allocate in total, or 0 for no limit")
+}
+
+// Creates an interpreter that reports errors in the given format and executes
+// programs with the given backend and any other options.
+func newInterpreter(format, backend string, extra ...lox.Option) (*lox.Interpreter, bool) {
+	opts := extra
+
+	switch format {
+	case "text":
+	case "json":
+		// Errors are reported as JSON by the command instead
+		opts = append(opts, lox.WithStderr(io.Discard))
+	default:
+		fmt.Fprintf(os.Stderr, "glox: unknown diagnostics format '%s'\n", format)
+		return nil, false
+	}
+
+	switch backend {
+	case "tree":
+	case "vm":
+		opts = append(opts, lox.WithBackend(vm.Backend{}))
+	default:
+		fmt.Fprintf(os.Stderr, "glox: unknown backend '%s'\n", backend)
+		return nil, false
+	}
+
+	return lox.NewInterpreter(opts...), true
+}
+
+// Reports an error returned by the interpreter as JSON diagnostics, if that
+// format was selected.
+func reportJSON(format, filename string, err error) {
+	if format != "json" {
+		return
+	}
+
+	enc := json.NewEncoder(os.Stderr)
+	for _, d := range lox.Diagnostics(filename, err) {
+		enc.Encode(d)
+	}
+}
+
+// Parses the flags of a command. Unless the arguments of the command are
+// passed through (e.g. to a script), flags may also follow them, as in "glox
+// build file.lox -o file.loxc".
+func parseFlags(fs *flag.FlagSet, args []string, interspersed bool) error {
+	if err := fs.Parse(args); err != nil || !interspersed {
+		return err
+	}
+
+	var positional []string
+	for fs.NArg() > 0 {
+		positional = append(positional, fs.Arg(0))
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return err
+		}
+	}
+
+	return fs.Parse(positional)
+}
+
+// Reports whether a flag was given on the command line, rather than having
+// its default value.
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func readFile(filename string) (string, bool) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not read file '%s'\n", filename)
+		return "", false
+	}
+	return string(data), true
+}
+
+func runCommand(cmd *command, args []string) int {
+	fs := newFlagSet(cmd)
+	format := diagnosticsFlag(fs)
+	backend := backendFlag(fs)
+	allocLimit := allocLimitFlag(fs)
+	if status, ok := parseArgs(fs, args, 1, -1); !ok {
+		return status
+	}
+
+	in, ok := newInterpreter(*format, *backend, lox.WithAllocationLimit(*allocLimit<<20))
+	if !ok {
+		return exitUsage
+	}
+
+	filename := fs.Arg(0)
+	defineArgs(in.Globals(), fs.Args()[1:])
+
+	if filepath.Ext(filename) == ".loxc" {
+		if isFlagSet(fs, "backend") && *backend != "vm" {
+			fmt.Fprintf(os.Stderr, "glox %s: bytecode files can only run with -backend=vm\n", cmd.name)
+			return exitUsage
+		}
+		return runCompiled(in, *format, filename)
+	}
+
+	source, ok := readFile(filename)
+	if !ok {
+		return exitIOErr
+	}
+
+	err := in.Run(source)
+	reportJSON(*format, filename, err)
+	return lox.ExitStatus(err)
+}
+
+// Runs a compiled file on the virtual machine, which is the only backend that
+// can execute bytecode.
+func runCompiled(in *lox.Interpreter, format, filename string) int {
+	script, status := readCompiled(format, filename)
+	if script == nil {
+		return status
+	}
+
+	// The source code is not available to show in errors
+	err := vm.Run(context.Background(), in.Config(), script)
+	in.ReportError("", err)
+	reportJSON(format, filename, err)
+	return lox.ExitStatus(err)
+}
+
+// Defines the native functions that give a script access to its arguments.
+func defineArgs(globals *lox.Environment, args []string) {
+	globals.DefineNative("argc", 0, func([]lox.Value) (lox.Value, error) {
+		return lox.NumberValue(float64(len(args))), nil
+	})
+
+	globals.DefineNative("argv", 1, func(values []lox.Value) (lox.Value, error) {
+		if i := values[0].AsNumber(); values[0].Type() == lox.TypeNumber && i == float64(int(i)) && 0 <= i && int(i) < len(args) {
+			return lox.StringValue(args[int(i)]), nil
+		}
+		return lox.Value{}, &lox.Error{Msg: "Argument index out of range"}
+	})
+}
+
+func replCommand(cmd *command, args []string) int {
+	fs := newFlagSet(cmd)
+	format := diagnosticsFlag(fs)
+	backend := backendFlag(fs)
+	allocLimit := allocLimitFlag(fs)
+	if status, ok := parseArgs(fs, args, 0, 0); !ok {
+		return status
+	}
+
+	in, ok := newInterpreter(*format, *backend, lox.WithAllocationLimit(*allocLimit<<20))
+	if !ok {
+		return exitUsage
+	}
+
+	editor := lineedit.New(os.Stdin, os.Stdout)
+	editor.SetCompleter(token.IsIdentPart, func(prefix string) []string {
+		return completions(in, prefix)
+	})
+	if home, err := os.UserHomeDir(); err == nil {
+		// The REPL is still usable without history, so errors are ignored
+		editor.SetHistoryFile(filepath.Join(home, ".glox_history"))
+	}
+
+	var input strings.Builder
+
+	for {
+		prompt := ">> "
+		if input.Len() > 0 {
+			prompt = ".. "
+		}
+
+		line, err := editor.ReadLine(prompt)
+		if errors.Is(err, lineedit.ErrInterrupted) {
+			input.Reset()
+			continue
+		}
+		if err != nil {
+			break
+		}
+
+		continuing := input.Len() > 0
+
+		if continuing {
+			input.WriteString("\n")
+		}
+		input.WriteString(line)
+
+		// A blank continuation line runs the input as is, so that errors in
+		// incomplete input can still be reported
+		source := input.String()
+		if parser.IsIncomplete(source) && !(continuing && strings.TrimSpace(line) == "") {
+			continue
+		}
+
+		input.Reset()
+		reportJSON(*format, "", in.RunInteractive(source))
+	}
+
+	return lox.ExitOK
+}
+
+// Returns the keywords and global names starting with a prefix, for completion
+// in the REPL.
+func completions(in *lox.Interpreter, prefix string) []string {
+	var candidates []string
+	for _, name := range slices.Concat(token.Keywords(), in.Globals().Names()) {
+		if strings.HasPrefix(name, prefix) {
+			candidates = append(candidates, name)
+		}
+	}
+
+	slices.Sort(candidates)
+	return slices.Compact(candidates)
+}
+
+func tokensCommand(cmd *command, args []string) int {
+	fs := newFlagSet(cmd)
+	if status, ok := parseArgs(fs, args, 1, 1); !ok {
+		return status
+	}
+
+	source, ok := readFile(fs.Arg(0))
+	if !ok {
+		return exitIOErr
+	}
+
+	status := lox.ExitOK
+
+	for _, tok := range scanner.ScanSource(source) {
+		lexeme := tok.Lexeme
+		if tok.Type == token.ERROR {
+			status = lox.ExitCompileErr
+		} else {
+			lexeme = strconv.Quote(lexeme)
+		}
+		fmt.Printf("%d:%d\t%s\t%s\n", tok.Line, tok.Column, tok.Type, lexeme)
+	}
+
+	return status
+}
+
+func astCommand(cmd *command, args []string) int {
+	fs := newFlagSet(cmd)
+	format := diagnosticsFlag(fs)
+	if status, ok := parseArgs(fs, args, 1, 1); !ok {
+		return status
+	}
+
+	in, ok := newInterpreter(*format, "tree")
+	if !ok {
+		return exitUsage
+	}
+
+	filename := fs.Arg(0)
+	source, ok := readFile(filename)
+	if !ok {
+		return exitIOErr
+	}
+
+	parsed, err := in.Check(source)
+	if err != nil {
+		reportJSON(*format, filename, err)
+		return lox.ExitStatus(err)
+	}
+
+	for _, stmt := range parsed {
+		fmt.Print(ast.Print(stmt))
+	}
+
+	return lox.ExitOK
+}
+
+func disasmCommand(cmd *command, args []string) int {
+	fs := newFlagSet(cmd)
+	format := diagnosticsFlag(fs)
+	if status, ok := parseArgs(fs, args, 1, 1); !ok {
+		return status
+	}
+
+	var (
+		script *compiler.Function
+		status int
+	)
+
+	if filename := fs.Arg(0); filepath.Ext(filename) == ".loxc" {
+		script, status = readCompiled(*format, filename)
+	} else {
+		script, status = compileFile(*format, filename)
+	}
+	if script == nil {
+		return status
+	}
+
+	fmt.Print(compiler.Disassemble(script))
+
+	return lox.ExitOK
+}
+
+func buildCommand(cmd *command, args []string) int {
+	fs := newFlagSet(cmd)
+	format := diagnosticsFlag(fs)
+	output := fs.String("o", "", "output file (default: the input file with a .loxc extension)")
+	if status, ok := parseArgs(fs, args, 1, 1); !ok {
+		return status
+	}
+
+	filename := fs.Arg(0)
+	script, status := compileFile(*format, filename)
+	if script == nil {
+		return status
+	}
+
+	if *output == "" {
+		*output = strings.TrimSuffix(filename, filepath.Ext(filename)) + ".loxc"
+	}
+
+	if err := os.WriteFile(*output, compiler.Encode(script), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not write file '%s'\n", *output)
+		return exitIOErr
+	}
+
+	return lox.ExitOK
+}
+
+// Compiles a Lox script into bytecode, reporting errors in the given format.
+// The returned script is nil if compiling failed, along with the exit status
+// code.
+func compileFile(format, filename string) (*compiler.Function, int) {
+	in, ok := newInterpreter(format, "tree")
+	if !ok {
+		return nil, exitUsage
+	}
+
+	source, ok := readFile(filename)
+	if !ok {
+		return nil, exitIOErr
+	}
+
+	parsed, err := in.Check(source)
+	if err == nil {
+		var script *compiler.Function
+		if script, err = compiler.Compile(parsed); err == nil {
+			return script, lox.ExitOK
+		}
+		in.ReportError(source, err)
+	}
+
+	reportJSON(format, filename, err)
+	return nil, lox.ExitStatus(err)
+}
+
+// Reads a compiled file written by the build command, reporting decoding errors
+// in the given format. The returned script is nil if the file could not be
+// read or decoded, along with the exit status code.
+func readCompiled(format, filename string) (*compiler.Function, int) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not read file '%s'\n", filename)
+		return nil, exitIOErr
+	}
+
+	script, err := compiler.Decode(data)
+	if err != nil {
+		if format == "json" {
+			// Like compile errors, the file cannot be run at all
+			json.NewEncoder(os.Stderr).Encode(lox.Diagnostic{
+				Severity: "error",
+				Message:  err.Error(),
+				File:     filename,
+				Phase:    "compile",
+			})
+		} else {
+			fmt.Fprintf(os.Stderr, "glox: %s: %v\n", filename, err)
+		}
+		return nil, lox.ExitCompileErr
+	}
+
+	return script, lox.ExitOK
+}
+
+func checkCommand(cmd *command, args []string) int {
+	fs := newFlagSet(cmd)
+	format := diagnosticsFlag(fs)
+	if status, ok := parseArgs(fs, args, 1, 1); !ok {
+		return status
+	}
+
+	in, ok := newInterpreter(*format, "tree")
+	if !ok {
+		return exitUsage
+	}
+
+	filename := fs.Arg(0)
+	source, ok := readFile(filename)
+	if !ok {
+		return exitIOErr
+	}
+
+	_, err := in.Check(source)
+	reportJSON(*format, filename, err)
+	return lox.ExitStatus(err)
+}
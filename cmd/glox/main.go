@@ -1,40 +1,91 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"os"
+	"strings"
+)
+
+const (
+	// The exit status code returned when the command was used incorrectly.
+	exitUsage = 64
 
-	"github.com/kevhlee/glox/pkg/lox"
+	// The exit status code returned when a file could not be read or written.
+	exitIOErr = 74
 )
 
+// A glox subcommand.
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(cmd *command, args []string) int
+}
+
+var commands = []*command{
+	{name: "run", args: "<file> [args...]", summary: "Run a Lox script or bytecode file", run: runCommand},
+	{name: "repl", args: "", summary: "Start an interactive Lox session", run: replCommand},
+	{name: "tokens", args: "<file>", summary: "Print the tokens of a Lox script", run: tokensCommand},
+	{name: "ast", args: "<file>", summary: "Print the syntax tree of a Lox script", run: astCommand},
+	{name: "build", args: "<file>", summary: "Compile a Lox script into a bytecode file", run: buildCommand},
+	{name: "disasm", args: "<file>", summary: "Print the bytecode of a Lox script", run: disasmCommand},
+	{name: "check", args: "<file>", summary: "Check a Lox script for compile errors without running it", run: checkCommand},
+}
+
 func main() {
-	globals := lox.NewEnvironment()
+	// Without a command, start a REPL like other scripting languages do
+	if len(os.Args) < 2 {
+		os.Exit(replCommand(findCommand("repl"), nil))
+	}
 
-	if len(os.Args) == 1 {
-		runREPL(globals)
-	} else {
-		runFile(globals, os.Args[1])
+	name := os.Args[1]
+
+	switch name {
+	case "help", "-h", "-help", "--help":
+		usage()
+		os.Exit(0)
 	}
+
+	if cmd := findCommand(name); cmd != nil {
+		os.Exit(cmd.run(cmd, os.Args[2:]))
+	}
+
+	// Before there were commands, scripts were run with 'glox <file>'
+	if isScript(name) {
+		cmd := findCommand("run")
+		os.Exit(cmd.run(cmd, os.Args[1:]))
+	}
+
+	fmt.Fprintf(os.Stderr, "glox: unknown command '%s'\n", name)
+	usage()
+	os.Exit(exitUsage)
 }
 
-func runREPL(globals *lox.Environment) {
-	reader := bufio.NewScanner(os.Stdin)
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: glox <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'glox <command> -h' for more information about a command.")
+}
 
-	for {
-		fmt.Print(">> ")
-		if !reader.Scan() {
-			break
-		}
-		lox.RunSource(globals, reader.Text())
+// Reports whether an argument that is not a command names a script to run.
+func isScript(arg string) bool {
+	if strings.HasSuffix(arg, ".lox") {
+		return true
 	}
+	info, err := os.Stat(arg)
+	return err == nil && !info.IsDir()
 }
 
-func runFile(globals *lox.Environment, filename string) {
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Could not read file '%s'", filename)
-		os.Exit(74)
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
 	}
-	os.Exit(lox.RunSource(globals, string(data)))
+	return nil
 }
@@ -0,0 +1,363 @@
+package lineedit
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+const (
+	keyCtrlA     = 0x01
+	keyCtrlB     = 0x02
+	keyCtrlC     = 0x03
+	keyCtrlD     = 0x04
+	keyCtrlE     = 0x05
+	keyCtrlF     = 0x06
+	keyCtrlG     = 0x07
+	keyCtrlH     = 0x08
+	keyTab       = 0x09
+	keyLineFeed  = 0x0a
+	keyCtrlK     = 0x0b
+	keyCtrlL     = 0x0c
+	keyEnter     = 0x0d
+	keyCtrlN     = 0x0e
+	keyCtrlP     = 0x10
+	keyCtrlR     = 0x12
+	keyCtrlU     = 0x15
+	keyCtrlW     = 0x17
+	keyEscape    = 0x1b
+	keyBackspace = 0x7f
+)
+
+// The state of the line being edited.
+type state struct {
+	prompt string
+	buf    []rune
+	pos    int
+
+	// The history entry being displayed, or len(history) for the new line
+	historyIndex int
+
+	// The new line, saved while browsing the history
+	saved []rune
+}
+
+// Edits a line in raw mode until it is entered or discarded.
+func (e *Editor) edit(prompt string) (string, error) {
+	s := &state{prompt: prompt, historyIndex: len(e.history)}
+	e.refresh(s)
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case keyEnter, keyLineFeed:
+			io.WriteString(e.out, "\r\n")
+			return string(s.buf), nil
+		case keyCtrlC:
+			io.WriteString(e.out, "^C\r\n")
+			return "", ErrInterrupted
+		case keyCtrlD:
+			if len(s.buf) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			s.deleteForward()
+		case keyCtrlA:
+			s.pos = 0
+		case keyCtrlE:
+			s.pos = len(s.buf)
+		case keyCtrlB:
+			s.moveLeft()
+		case keyCtrlF:
+			s.moveRight()
+		case keyCtrlP:
+			e.historyPrev(s)
+		case keyCtrlN:
+			e.historyNext(s)
+		case keyCtrlK:
+			s.buf = s.buf[:s.pos]
+		case keyCtrlU:
+			s.buf = slices.Delete(s.buf, 0, s.pos)
+			s.pos = 0
+		case keyCtrlW:
+			start := s.pos
+			for start > 0 && s.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && s.buf[start-1] != ' ' {
+				start--
+			}
+			s.buf = slices.Delete(s.buf, start, s.pos)
+			s.pos = start
+		case keyCtrlL:
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case keyCtrlR:
+			if e.search(s) {
+				io.WriteString(e.out, "\r\n")
+				return string(s.buf), nil
+			}
+		case keyTab:
+			e.completeWord(s)
+		case keyBackspace, keyCtrlH:
+			s.deleteBackward()
+		case keyEscape:
+			e.escape(s, e.readEscape())
+		default:
+			if r >= ' ' {
+				s.insert(r)
+			}
+		}
+
+		e.refresh(s)
+	}
+}
+
+// Redraws the line and places the cursor.
+func (e *Editor) refresh(s *state) {
+	e.draw(s.prompt, string(s.buf), len([]rune(s.prompt))+s.pos)
+}
+
+func (e *Editor) draw(prompt, line string, column int) {
+	var b strings.Builder
+
+	b.WriteString("\r")
+	b.WriteString(prompt)
+	b.WriteString(line)
+	b.WriteString("\x1b[K\r")
+	if column > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", column)
+	}
+
+	io.WriteString(e.out, b.String())
+}
+
+// Reads the rest of an escape sequence after the escape character, e.g. "[A"
+// for the up arrow key.
+//
+// If the escape character does not start a sequence, it was a press of the Esc
+// key, so the next key is left to be read normally and "" is returned.
+func (e *Editor) readEscape() string {
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return ""
+	}
+	if r != '[' && r != 'O' {
+		e.in.UnreadRune()
+		return ""
+	}
+
+	seq := []rune{r}
+
+	switch r {
+	case '[':
+		// Control sequences end with a character in the range '@' to '~'
+		for {
+			r, _, err := e.in.ReadRune()
+			if err != nil {
+				break
+			}
+			seq = append(seq, r)
+			if r >= '@' && r <= '~' {
+				break
+			}
+		}
+	case 'O':
+		if r, _, err := e.in.ReadRune(); err == nil {
+			seq = append(seq, r)
+		}
+	}
+
+	return string(seq)
+}
+
+// Handles an escape sequence for a special key.
+func (e *Editor) escape(s *state, seq string) {
+	switch seq {
+	case "[A", "OA":
+		e.historyPrev(s)
+	case "[B", "OB":
+		e.historyNext(s)
+	case "[C", "OC":
+		s.moveRight()
+	case "[D", "OD":
+		s.moveLeft()
+	case "[H", "OH", "[1~", "[7~":
+		s.pos = 0
+	case "[F", "OF", "[4~", "[8~":
+		s.pos = len(s.buf)
+	case "[3~":
+		s.deleteForward()
+	}
+}
+
+func (s *state) insert(r rune) {
+	s.buf = slices.Insert(s.buf, s.pos, r)
+	s.pos++
+}
+
+func (s *state) deleteBackward() {
+	if s.pos > 0 {
+		s.buf = slices.Delete(s.buf, s.pos-1, s.pos)
+		s.pos--
+	}
+}
+
+func (s *state) deleteForward() {
+	if s.pos < len(s.buf) {
+		s.buf = slices.Delete(s.buf, s.pos, s.pos+1)
+	}
+}
+
+func (s *state) moveLeft() {
+	if s.pos > 0 {
+		s.pos--
+	}
+}
+
+func (s *state) moveRight() {
+	if s.pos < len(s.buf) {
+		s.pos++
+	}
+}
+
+func (s *state) setLine(line []rune) {
+	s.buf = slices.Clone(line)
+	s.pos = len(s.buf)
+}
+
+func (e *Editor) historyPrev(s *state) {
+	if s.historyIndex == 0 {
+		return
+	}
+	if s.historyIndex == len(e.history) {
+		s.saved = slices.Clone(s.buf)
+	}
+
+	s.historyIndex--
+	s.setLine([]rune(e.history[s.historyIndex]))
+}
+
+func (e *Editor) historyNext(s *state) {
+	if s.historyIndex == len(e.history) {
+		return
+	}
+
+	s.historyIndex++
+	if s.historyIndex == len(e.history) {
+		s.setLine(s.saved)
+	} else {
+		s.setLine([]rune(e.history[s.historyIndex]))
+	}
+}
+
+// Searches the history backwards for entries containing a query typed by the
+// user. The function returns true if the match was entered, otherwise the
+// match (if any) is left in the line for editing.
+func (e *Editor) search(s *state) bool {
+	var (
+		query    []rune
+		index    = len(e.history)
+		match    = slices.Clone(s.buf)
+		original = slices.Clone(s.buf)
+		failed   = false
+	)
+
+	// Finds the closest match at or before a history entry
+	find := func(from int) {
+		for i := min(from, len(e.history)-1); i >= 0; i-- {
+			if strings.Contains(e.history[i], string(query)) {
+				index, match, failed = i, []rune(e.history[i]), false
+				return
+			}
+		}
+		failed = true
+	}
+
+	for {
+		prompt := "(reverse-i-search)`"
+		if failed {
+			prompt = "(failed reverse-i-search)`"
+		}
+		prompt += string(query) + "': "
+		e.draw(prompt, string(match), len([]rune(prompt)))
+
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return false
+		}
+
+		switch r {
+		case keyEnter, keyLineFeed:
+			s.setLine(match)
+			return true
+		case keyCtrlC, keyCtrlG:
+			s.setLine(original)
+			return false
+		case keyCtrlR:
+			find(index - 1)
+		case keyBackspace, keyCtrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				find(len(e.history))
+			}
+		case keyEscape:
+			s.setLine(match)
+			e.escape(s, e.readEscape())
+			return false
+		default:
+			if r < ' ' {
+				s.setLine(match)
+				return false
+			}
+			query = append(query, r)
+			find(index)
+		}
+	}
+}
+
+// Completes the identifier before the cursor. If there are several candidates,
+// their common prefix is inserted, or they are listed if there is none.
+func (e *Editor) completeWord(s *state) {
+	if e.complete == nil {
+		io.WriteString(e.out, "\a")
+		return
+	}
+
+	start := s.pos
+	for start > 0 && e.isWordChar(s.buf[start-1]) {
+		start--
+	}
+
+	prefix := string(s.buf[start:s.pos])
+
+	candidates := e.complete(prefix)
+	if len(candidates) == 0 {
+		io.WriteString(e.out, "\a")
+		return
+	}
+
+	common := candidates[0]
+	for _, candidate := range candidates[1:] {
+		n := 0
+		for n < len(common) && n < len(candidate) && common[n] == candidate[n] {
+			n++
+		}
+		common = common[:n]
+	}
+
+	if len(common) > len(prefix) {
+		for _, r := range common[len(prefix):] {
+			s.insert(r)
+		}
+		return
+	}
+
+	if len(candidates) > 1 {
+		io.WriteString(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+	}
+}
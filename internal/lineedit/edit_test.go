@@ -0,0 +1,160 @@
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// TestEdit checks that key presses edit the line correctly.
+func TestEdit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"print 1;\r", "print 1;"},
+		{"print 1;\n", "print 1;"},
+		{"prnt\x1b[D\x1b[Di\r", "print"},
+		{"int\x01pr\x05 1;\r", "print 1;"},
+		{"print 12\x7f3;\r", "print 13;"},
+		{"print 12;\x02\x02\x02\x1b[3~\r", "print 2;"},
+		{"abc def\x17\r", "abc "},
+		{"abc def\x02\x02\x02\x0b\r", "abc "},
+		{"abc def\x02\x02\x02\x15\r", "def"},
+		{"abc\x1b[Hx\x1b[Fy\r", "xabcy"},
+		{"abc\x1bOHx\x1bOFy\r", "xabcy"},
+		{"ab\x1bc\r", "abc"},
+		{"ab\x1b\x7f\r", "a"},
+	}
+
+	for _, test := range tests {
+		if actual, err := testEdit(t, nil, test.input); err != nil {
+			t.Errorf("Unexpected error for %q: %v", test.input, err)
+		} else if actual != test.expected {
+			t.Errorf("Expected %q for %q but got %q", test.expected, test.input, actual)
+		}
+	}
+}
+
+// TestEditSignals checks that Ctrl-C and Ctrl-D end editing with the correct
+// error.
+func TestEditSignals(t *testing.T) {
+	if _, err := testEdit(t, nil, "abc\x03"); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("Expected ErrInterrupted but got %v", err)
+	}
+	if _, err := testEdit(t, nil, "\x04"); err != io.EOF {
+		t.Errorf("Expected io.EOF but got %v", err)
+	}
+	if line, err := testEdit(t, nil, "ab\x01\x04\r"); err != nil || line != "b" {
+		t.Errorf("Expected \"b\" but got %q (%v)", line, err)
+	}
+}
+
+// TestEditHistory checks that the history can be browsed and searched.
+func TestEditHistory(t *testing.T) {
+	history := []string{"var a = 1;", "print a;", "var b = 2;"}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"\x1b[A\r", "var b = 2;"},
+		{"\x1b[A\x1b[A\x1b[A\x1b[A\r", "var a = 1;"},
+		{"x\x1b[A\x1b[B\r", "x"},
+		{"\x10\x10\x0e\r", "var b = 2;"},
+		{"\x12var\r", "var b = 2;"},
+		{"\x12var\x12\r", "var a = 1;"},
+		{"\x12print\x1b[D\x7f\r", "print ;"},
+		{"x\x12zzz\x07\r", "x"},
+	}
+
+	for _, test := range tests {
+		if actual, err := testEdit(t, history, test.input); err != nil {
+			t.Errorf("Unexpected error for %q: %v", test.input, err)
+		} else if actual != test.expected {
+			t.Errorf("Expected %q for %q but got %q", test.expected, test.input, actual)
+		}
+	}
+}
+
+// TestEditComplete checks that the word before the cursor is completed.
+func TestEditComplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"pr\t\r", "print"},
+		{"fa\t\r", "false"},
+		{"f\t\r", "f"},
+		{"var x = count\t\r", "var x = counter"},
+		{"zzz\t\r", "zzz"},
+		{"(pr\t\r", "(print"},
+	}
+
+	for _, test := range tests {
+		if actual, err := testEdit(t, nil, test.input); err != nil {
+			t.Errorf("Unexpected error for %q: %v", test.input, err)
+		} else if actual != test.expected {
+			t.Errorf("Expected %q for %q but got %q", test.expected, test.input, actual)
+		}
+	}
+}
+
+// TestHistoryFile checks that the history is loaded from and saved to a file.
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	if err := os.WriteFile(path, []byte("print 1;\nprint 2;\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var e Editor
+	if err := e.SetHistoryFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"print 1;", "print 2;"}; !slices.Equal(e.history, expected) {
+		t.Errorf("Expected history %q but got %q", expected, e.history)
+	}
+
+	e.addHistory("print 3;")
+	e.addHistory("print 3;")
+	e.addHistory("  ")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "print 1;\nprint 2;\nprint 3;\n"; string(data) != expected {
+		t.Errorf("Expected history file %q but got %q", expected, data)
+	}
+}
+
+func testEdit(t *testing.T, history []string, input string) (string, error) {
+	t.Helper()
+
+	e := &Editor{
+		in:      bufio.NewReader(strings.NewReader(input)),
+		out:     io.Discard,
+		history: history,
+	}
+	e.SetCompleter(isIdentChar, func(prefix string) []string {
+		var candidates []string
+		for _, word := range []string{"counter", "false", "for", "fun", "print"} {
+			if strings.HasPrefix(word, prefix) {
+				candidates = append(candidates, word)
+			}
+		}
+		return candidates
+	})
+
+	return e.edit("> ")
+}
+
+func isIdentChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,163 @@
+// Package lineedit implements a minimal terminal line editor with history and
+// completion.
+//
+// The editor puts the terminal in raw mode through system calls, so it does not
+// depend on cgo or readline. If the input is not a terminal, lines are read
+// without editing.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrInterrupted is returned by [Editor.ReadLine] when the user discards the
+// line with Ctrl-C.
+var ErrInterrupted = errors.New("interrupted")
+
+// The maximum number of history entries kept.
+const maxHistory = 1000
+
+// Editor reads lines of input from a terminal.
+//
+// The following key bindings are supported:
+//
+//   - Left/Right, Ctrl-B/Ctrl-F: move the cursor
+//   - Home/End, Ctrl-A/Ctrl-E: move to the start/end of the line
+//   - Up/Down, Ctrl-P/Ctrl-N: browse the history
+//   - Ctrl-R: search the history backwards
+//   - Tab: complete the word before the cursor
+//   - Backspace, Delete: delete a character
+//   - Ctrl-K/Ctrl-U: delete to the end/start of the line
+//   - Ctrl-W: delete the word before the cursor
+//   - Ctrl-L: clear the screen
+//   - Ctrl-C: discard the line
+//   - Ctrl-D: end the input if the line is empty
+type Editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	fd       int
+	terminal bool
+
+	history     []string
+	historyFile string
+	isWordChar  func(r rune) bool
+	complete    func(prefix string) []string
+}
+
+// New creates a line editor reading from in and echoing to out.
+func New(in *os.File, out io.Writer) *Editor {
+	_, err := getTermios(int(in.Fd()))
+
+	return &Editor{
+		in:       bufio.NewReader(in),
+		out:      out,
+		fd:       int(in.Fd()),
+		terminal: err == nil,
+	}
+}
+
+// SetCompleter sets the function used to complete the word before the cursor.
+// The word is made of the characters before the cursor for which isWordChar
+// returns true, and complete returns the candidates starting with it.
+func (e *Editor) SetCompleter(isWordChar func(r rune) bool, complete func(prefix string) []string) {
+	e.isWordChar = isWordChar
+	e.complete = complete
+}
+
+// SetHistoryFile loads the history from a file and appends entered lines to it
+// from now on. A missing file is not an error.
+func (e *Editor) SetHistoryFile(path string) error {
+	e.historyFile = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	e.trimHistory()
+
+	return nil
+}
+
+// ReadLine displays a prompt and reads a line of input.
+//
+// [io.EOF] is returned at the end of the input, and [ErrInterrupted] if the
+// line was discarded.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if !e.terminal {
+		return e.readPlain(prompt)
+	}
+
+	orig, err := getTermios(e.fd)
+	if err != nil {
+		return e.readPlain(prompt)
+	}
+	if err := setTermios(e.fd, makeRaw(*orig)); err != nil {
+		return e.readPlain(prompt)
+	}
+	defer setTermios(e.fd, orig)
+
+	line, err := e.edit(prompt)
+	if err == nil {
+		e.addHistory(line)
+	}
+	return line, err
+}
+
+// Reads a line without editing, for input that is not a terminal.
+func (e *Editor) readPlain(prompt string) (string, error) {
+	io.WriteString(e.out, prompt)
+
+	line, err := e.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return line, nil
+}
+
+// Adds a line to the history, skipping blank lines and immediate repeats.
+func (e *Editor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+
+	e.history = append(e.history, line)
+	e.trimHistory()
+
+	if e.historyFile == "" {
+		return
+	}
+
+	// History is a convenience, so failing to save it is not reported
+	f, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	io.WriteString(f, line+"\n")
+}
+
+func (e *Editor) trimHistory() {
+	if n := len(e.history); n > maxHistory {
+		e.history = e.history[n-maxHistory:]
+	}
+}
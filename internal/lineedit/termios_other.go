@@ -0,0 +1,24 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package lineedit
+
+import "errors"
+
+// Line editing is not supported on this platform, so input is always read
+// line by line.
+
+type termios struct{}
+
+var errUnsupported = errors.New("terminal line editing is not supported")
+
+func getTermios(fd int) (*termios, error) {
+	return nil, errUnsupported
+}
+
+func setTermios(fd int, t *termios) error {
+	return errUnsupported
+}
+
+func makeRaw(t termios) *termios {
+	return &t
+}
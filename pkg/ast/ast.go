@@ -20,7 +20,17 @@ type (
 
 	// BlockStmt is a block statement AST node.
 	BlockStmt struct {
-		Body []Stmt
+		// The opening brace, or the 'for' keyword of a block desugared from
+		// a for loop
+		Brace *token.Token
+		Body  []Stmt
+	}
+
+	// ClassStmt is a class declaration statement AST node.
+	ClassStmt struct {
+		Name       *token.Token
+		Superclass *VariableExpr
+		Methods    []*FunctionStmt
 	}
 
 	// ExpressionStmt is an expression statement AST node.
@@ -28,30 +38,71 @@ type (
 		Expression Expr
 	}
 
+	// FunctionStmt is a function declaration statement AST node.
+	FunctionStmt struct {
+		Name   *token.Token
+		Params []*token.Token
+		Body   []Stmt
+	}
+
+	// IfStmt is an if statement AST node.
+	IfStmt struct {
+		Condition Expr
+		Then      Stmt
+		Else      Stmt
+	}
+
 	// PrintStmt is a print statement AST node.
 	PrintStmt struct {
 		Value Expr
 	}
 
+	// ReturnStmt is a return statement AST node.
+	ReturnStmt struct {
+		Keyword *token.Token
+		Value   Expr
+	}
+
 	// VarStmt is a variable declaration statement AST node.
 	VarStmt struct {
 		Name  *token.Token
 		Value Expr
 	}
+
+	// WhileStmt is a while loop statement AST node.
+	WhileStmt struct {
+		Condition Expr
+		Body      Stmt
+	}
 )
 
 func (*BlockStmt) node() {}
 func (*BlockStmt) stmt() {}
 
+func (*ClassStmt) node() {}
+func (*ClassStmt) stmt() {}
+
 func (*ExpressionStmt) node() {}
 func (*ExpressionStmt) stmt() {}
 
+func (*FunctionStmt) node() {}
+func (*FunctionStmt) stmt() {}
+
+func (*IfStmt) node() {}
+func (*IfStmt) stmt() {}
+
 func (*PrintStmt) node() {}
 func (*PrintStmt) stmt() {}
 
+func (*ReturnStmt) node() {}
+func (*ReturnStmt) stmt() {}
+
 func (*VarStmt) node() {}
 func (*VarStmt) stmt() {}
 
+func (*WhileStmt) node() {}
+func (*WhileStmt) stmt() {}
+
 //
 // Expr
 //
@@ -67,6 +118,11 @@ type (
 	AssignExpr struct {
 		Name  *token.Token
 		Value Expr
+
+		// The number of scopes between the assignment and the variable's
+		// binding, as computed by the resolver. A negative depth refers to a
+		// global variable.
+		Depth int
 	}
 
 	// BinaryExpr is a binary expression AST node.
@@ -76,6 +132,19 @@ type (
 		Right    Expr
 	}
 
+	// CallExpr is a call expression AST node.
+	CallExpr struct {
+		Callee Expr
+		Paren  *token.Token
+		Args   []Expr
+	}
+
+	// GetExpr is a property access expression AST node.
+	GetExpr struct {
+		Object Expr
+		Name   *token.Token
+	}
+
 	// GroupingExpr is a grouped expression AST node.
 	GroupingExpr struct {
 		Group Expr
@@ -86,6 +155,39 @@ type (
 		Value *token.Token
 	}
 
+	// LogicalExpr is a short-circuiting logical expression AST node.
+	LogicalExpr struct {
+		Left     Expr
+		Operator *token.Token
+		Right    Expr
+	}
+
+	// SetExpr is a property assignment expression AST node.
+	SetExpr struct {
+		Object Expr
+		Name   *token.Token
+		Value  Expr
+	}
+
+	// SuperExpr is a 'super' method access expression AST node.
+	SuperExpr struct {
+		Keyword *token.Token
+		Method  *token.Token
+
+		// The number of scopes between the expression and the binding of
+		// 'super', as computed by the resolver.
+		Depth int
+	}
+
+	// ThisExpr is a 'this' expression AST node.
+	ThisExpr struct {
+		Keyword *token.Token
+
+		// The number of scopes between the expression and the binding of
+		// 'this', as computed by the resolver.
+		Depth int
+	}
+
 	// UnaryExpr is a unary expression AST node.
 	UnaryExpr struct {
 		Operator *token.Token
@@ -95,6 +197,11 @@ type (
 	// VariableExpr is a variable expression AST node.
 	VariableExpr struct {
 		Name *token.Token
+
+		// The number of scopes between the expression and the variable's
+		// binding, as computed by the resolver. A negative depth refers to a
+		// global variable.
+		Depth int
 	}
 )
 
@@ -104,12 +211,30 @@ func (*AssignExpr) expr() {}
 func (*BinaryExpr) node() {}
 func (*BinaryExpr) expr() {}
 
+func (*CallExpr) node() {}
+func (*CallExpr) expr() {}
+
+func (*GetExpr) node() {}
+func (*GetExpr) expr() {}
+
 func (*GroupingExpr) node() {}
 func (*GroupingExpr) expr() {}
 
 func (*LiteralExpr) node() {}
 func (*LiteralExpr) expr() {}
 
+func (*LogicalExpr) node() {}
+func (*LogicalExpr) expr() {}
+
+func (*SetExpr) node() {}
+func (*SetExpr) expr() {}
+
+func (*SuperExpr) node() {}
+func (*SuperExpr) expr() {}
+
+func (*ThisExpr) node() {}
+func (*ThisExpr) expr() {}
+
 func (*UnaryExpr) node() {}
 func (*UnaryExpr) expr() {}
 
@@ -23,17 +23,44 @@ func Children(node Node) (children []Node) {
 			children = append(children, b)
 		}
 
+	case *ClassStmt:
+		if node.Superclass != nil {
+			children = append(children, node.Superclass)
+		}
+		for _, method := range node.Methods {
+			children = append(children, method)
+		}
+
 	case *ExpressionStmt:
 		children = append(children, node.Expression)
 
+	case *FunctionStmt:
+		for _, b := range node.Body {
+			children = append(children, b)
+		}
+
+	case *IfStmt:
+		children = append(children, node.Condition, node.Then)
+		if node.Else != nil {
+			children = append(children, node.Else)
+		}
+
 	case *PrintStmt:
 		children = append(children, node.Value)
 
+	case *ReturnStmt:
+		if node.Value != nil {
+			children = append(children, node.Value)
+		}
+
 	case *VarStmt:
 		if node.Value != nil {
 			children = append(children, node.Value)
 		}
 
+	case *WhileStmt:
+		children = append(children, node.Condition, node.Body)
+
 	// Expr
 
 	case *AssignExpr:
@@ -42,12 +69,33 @@ func Children(node Node) (children []Node) {
 	case *BinaryExpr:
 		children = append(children, node.Left, node.Right)
 
+	case *CallExpr:
+		children = append(children, node.Callee)
+		for _, arg := range node.Args {
+			children = append(children, arg)
+		}
+
+	case *GetExpr:
+		children = append(children, node.Object)
+
 	case *GroupingExpr:
 		children = append(children, node.Group)
 
 	case *LiteralExpr:
 		// No children :(
 
+	case *LogicalExpr:
+		children = append(children, node.Left, node.Right)
+
+	case *SetExpr:
+		children = append(children, node.Object, node.Value)
+
+	case *SuperExpr:
+		// No children :(
+
+	case *ThisExpr:
+		// No children :(
+
 	case *UnaryExpr:
 		children = append(children, node.Right)
 
@@ -81,17 +129,46 @@ func Walk(visitor Visitor, node Node) {
 			Walk(visitor, b)
 		}
 
+	case *ClassStmt:
+		if node.Superclass != nil {
+			Walk(visitor, node.Superclass)
+		}
+		for _, method := range node.Methods {
+			Walk(visitor, method)
+		}
+
 	case *ExpressionStmt:
 		Walk(visitor, node.Expression)
 
+	case *FunctionStmt:
+		for _, b := range node.Body {
+			Walk(visitor, b)
+		}
+
+	case *IfStmt:
+		Walk(visitor, node.Condition)
+		Walk(visitor, node.Then)
+		if node.Else != nil {
+			Walk(visitor, node.Else)
+		}
+
 	case *PrintStmt:
 		Walk(visitor, node.Value)
 
+	case *ReturnStmt:
+		if node.Value != nil {
+			Walk(visitor, node.Value)
+		}
+
 	case *VarStmt:
 		if node.Value != nil {
 			Walk(visitor, node.Value)
 		}
 
+	case *WhileStmt:
+		Walk(visitor, node.Condition)
+		Walk(visitor, node.Body)
+
 	// Expr
 
 	case *AssignExpr:
@@ -101,12 +178,35 @@ func Walk(visitor Visitor, node Node) {
 		Walk(visitor, node.Left)
 		Walk(visitor, node.Right)
 
+	case *CallExpr:
+		Walk(visitor, node.Callee)
+		for _, arg := range node.Args {
+			Walk(visitor, arg)
+		}
+
+	case *GetExpr:
+		Walk(visitor, node.Object)
+
 	case *GroupingExpr:
 		Walk(visitor, node.Group)
 
 	case *LiteralExpr:
 		// Do nothing
 
+	case *LogicalExpr:
+		Walk(visitor, node.Left)
+		Walk(visitor, node.Right)
+
+	case *SetExpr:
+		Walk(visitor, node.Object)
+		Walk(visitor, node.Value)
+
+	case *SuperExpr:
+		// Do nothing
+
+	case *ThisExpr:
+		// Do nothing
+
 	case *UnaryExpr:
 		Walk(visitor, node.Right)
 
@@ -47,17 +47,43 @@ func (p *printer) Visit(node Node) bool {
 	case *BlockStmt:
 		p.WriteString("BLOCK\n")
 
+	case *ClassStmt:
+		p.WriteString("CLASS(")
+		p.WriteString(node.Name.Lexeme)
+		p.WriteString(")\n")
+
 	case *ExpressionStmt:
 		p.WriteString("EXPRESSION\n")
 
+	case *FunctionStmt:
+		p.WriteString("FUN(")
+		p.WriteString(node.Name.Lexeme)
+		p.WriteString("(")
+		for i, param := range node.Params {
+			if i > 0 {
+				p.WriteString(", ")
+			}
+			p.WriteString(param.Lexeme)
+		}
+		p.WriteString("))\n")
+
+	case *IfStmt:
+		p.WriteString("IF\n")
+
 	case *PrintStmt:
 		p.WriteString("PRINT\n")
 
+	case *ReturnStmt:
+		p.WriteString("RETURN\n")
+
 	case *VarStmt:
 		p.WriteString("VAR(")
 		p.WriteString(node.Name.Lexeme)
 		p.WriteString(")\n")
 
+	case *WhileStmt:
+		p.WriteString("WHILE\n")
+
 	// Expr
 
 	case *AssignExpr:
@@ -70,6 +96,14 @@ func (p *printer) Visit(node Node) bool {
 		p.WriteString(node.Operator.Lexeme)
 		p.WriteString(")\n")
 
+	case *CallExpr:
+		p.WriteString("CALL\n")
+
+	case *GetExpr:
+		p.WriteString("GET(")
+		p.WriteString(node.Name.Lexeme)
+		p.WriteString(")\n")
+
 	case *GroupingExpr:
 		p.WriteString("GROUP\n")
 
@@ -88,6 +122,24 @@ func (p *printer) Visit(node Node) bool {
 			p.WriteString(")\n")
 		}
 
+	case *LogicalExpr:
+		p.WriteString("LOGICAL(")
+		p.WriteString(node.Operator.Lexeme)
+		p.WriteString(")\n")
+
+	case *SetExpr:
+		p.WriteString("SET(")
+		p.WriteString(node.Name.Lexeme)
+		p.WriteString(")\n")
+
+	case *SuperExpr:
+		p.WriteString("SUPER(")
+		p.WriteString(node.Method.Lexeme)
+		p.WriteString(")\n")
+
+	case *ThisExpr:
+		p.WriteString("THIS\n")
+
 	case *UnaryExpr:
 		p.WriteString("UNARY(")
 		p.WriteString(node.Operator.Lexeme)
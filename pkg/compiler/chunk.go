@@ -0,0 +1,216 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/kevhlee/glox/pkg/lox"
+	"github.com/kevhlee/glox/pkg/token"
+)
+
+// OpCode is a bytecode instruction.
+//
+// Each instruction is encoded as its opcode followed by its operands. Constant
+// indices and jump offsets are 16-bit big-endian operands, and all other
+// operands are single bytes.
+type OpCode byte
+
+// String implements [fmt.Stringer] interface.
+func (op OpCode) String() string {
+	if name, ok := opNames[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("OP_UNKNOWN(%d)", byte(op))
+}
+
+// Returns the size in bytes of the operands of an instruction.
+//
+// An OpClosure instruction is also followed by the operands of each variable
+// it captures, which are read with readUpvalues.
+func (op OpCode) operandSize() int {
+	switch op {
+	case OpGetLocal, OpSetLocal, OpGetUpvalue, OpSetUpvalue, OpCall:
+		return 1
+	case OpConstant, OpGetGlobal, OpDefineGlobal, OpSetGlobal, OpGetProperty, OpSetProperty,
+		OpGetSuper, OpClass, OpMethod, OpJump, OpJumpIfFalse, OpLoop, OpClosure:
+		return 2
+	}
+	return 0
+}
+
+// Returns the number of values an instruction pops from the stack and pushes
+// onto it, where values that are only read count as both.
+//
+// An OpCall instruction also pops the arguments given by its operand.
+func (op OpCode) stackEffect() (pops, pushes int) {
+	switch op {
+	case OpConstant, OpNil, OpTrue, OpFalse, OpGetLocal, OpGetGlobal, OpGetUpvalue, OpClosure, OpClass:
+		return 0, 1
+	case OpPop, OpDefineGlobal, OpPrint, OpCloseUpvalue, OpReturn:
+		return 1, 0
+	case OpSetLocal, OpSetGlobal, OpSetUpvalue, OpGetProperty, OpNot, OpNegate, OpJumpIfFalse, OpCall:
+		return 1, 1
+	case OpSetProperty, OpGetSuper, OpEqual, OpGreater, OpGreaterEqual, OpLess, OpLessEqual,
+		OpAdd, OpSubtract, OpMultiply, OpDivide, OpInherit, OpMethod:
+		return 2, 1
+	}
+	return 0, 0
+}
+
+const (
+	OpConstant     OpCode = iota // Push constant (index)
+	OpNil                        // Push nil
+	OpTrue                       // Push true
+	OpFalse                      // Push false
+	OpPop                        // Pop a value
+	OpGetLocal                   // Push local (slot)
+	OpSetLocal                   // Assign local (slot)
+	OpGetGlobal                  // Push global (name index)
+	OpDefineGlobal               // Define global (name index)
+	OpSetGlobal                  // Assign global (name index)
+	OpGetUpvalue                 // Push upvalue (index)
+	OpSetUpvalue                 // Assign upvalue (index)
+	OpGetProperty                // Push property of instance (name index)
+	OpSetProperty                // Assign property of instance (name index)
+	OpGetSuper                   // Push superclass method bound to 'this' (name index)
+	OpEqual                      // ==
+	OpGreater                    // >
+	OpGreaterEqual               // >=
+	OpLess                       // <
+	OpLessEqual                  // <=
+	OpAdd                        // +
+	OpSubtract                   // -
+	OpMultiply                   // *
+	OpDivide                     // /
+	OpNot                        // !
+	OpNegate                     // Unary -
+	OpPrint                      // Print a value
+	OpJump                       // Jump forward (offset)
+	OpJumpIfFalse                // Jump forward if falsey, without popping (offset)
+	OpLoop                       // Jump backward (offset)
+	OpCall                       // Call a value (argument count)
+	OpClosure                    // Push closure (function index, then is-local and index per upvalue)
+	OpCloseUpvalue               // Hoist the local on top of the stack into the heap
+	OpReturn                     // Return from the current function
+	OpClass                      // Push class (name index)
+	OpInherit                    // Inherit the methods of a superclass
+	OpMethod                     // Define method of class (name index)
+)
+
+var opNames = map[OpCode]string{
+	OpConstant:     "OP_CONSTANT",
+	OpNil:          "OP_NIL",
+	OpTrue:         "OP_TRUE",
+	OpFalse:        "OP_FALSE",
+	OpPop:          "OP_POP",
+	OpGetLocal:     "OP_GET_LOCAL",
+	OpSetLocal:     "OP_SET_LOCAL",
+	OpGetGlobal:    "OP_GET_GLOBAL",
+	OpDefineGlobal: "OP_DEFINE_GLOBAL",
+	OpSetGlobal:    "OP_SET_GLOBAL",
+	OpGetUpvalue:   "OP_GET_UPVALUE",
+	OpSetUpvalue:   "OP_SET_UPVALUE",
+	OpGetProperty:  "OP_GET_PROPERTY",
+	OpSetProperty:  "OP_SET_PROPERTY",
+	OpGetSuper:     "OP_GET_SUPER",
+	OpEqual:        "OP_EQUAL",
+	OpGreater:      "OP_GREATER",
+	OpGreaterEqual: "OP_GREATER_EQUAL",
+	OpLess:         "OP_LESS",
+	OpLessEqual:    "OP_LESS_EQUAL",
+	OpAdd:          "OP_ADD",
+	OpSubtract:     "OP_SUBTRACT",
+	OpMultiply:     "OP_MULTIPLY",
+	OpDivide:       "OP_DIVIDE",
+	OpNot:          "OP_NOT",
+	OpNegate:       "OP_NEGATE",
+	OpPrint:        "OP_PRINT",
+	OpJump:         "OP_JUMP",
+	OpJumpIfFalse:  "OP_JUMP_IF_FALSE",
+	OpLoop:         "OP_LOOP",
+	OpCall:         "OP_CALL",
+	OpClosure:      "OP_CLOSURE",
+	OpCloseUpvalue: "OP_CLOSE_UPVALUE",
+	OpReturn:       "OP_RETURN",
+	OpClass:        "OP_CLASS",
+	OpInherit:      "OP_INHERIT",
+	OpMethod:       "OP_METHOD",
+}
+
+// Chunk is a sequence of bytecode instructions with the constants they refer
+// to.
+type Chunk struct {
+	Code []byte
+
+	// The constant pool, which contains numbers, strings and [*Function]
+	// objects.
+	Constants []lox.Value
+
+	// The source line of each byte of code.
+	Lines []int
+
+	// The token each byte of code was compiled from, used to report runtime
+	// errors. This is nil if the chunk was not compiled from source code.
+	Tokens []*token.Token
+}
+
+// Write appends a byte of code compiled from a token.
+func (c *Chunk) Write(b byte, tok *token.Token) {
+	c.Code = append(c.Code, b)
+	c.Lines = append(c.Lines, tok.Line)
+	c.Tokens = append(c.Tokens, tok)
+}
+
+// Token returns the token the instruction at an offset was compiled from, or
+// nil if it is unknown.
+func (c *Chunk) Token(offset int) *token.Token {
+	if offset < len(c.Tokens) {
+		return c.Tokens[offset]
+	}
+	return nil
+}
+
+// ReadShort reads a 16-bit operand at an offset.
+func (c *Chunk) ReadShort(offset int) int {
+	return int(c.Code[offset])<<8 | int(c.Code[offset+1])
+}
+
+// Reads the variables captured by the OpClosure instruction at an offset,
+// given the function it creates. If the code ends before the operands of every
+// variable, only those that are complete are returned, along with false.
+func (c *Chunk) readUpvalues(offset int, fn *Function) ([]upvalue, bool) {
+	offset += 1 + OpClosure.operandSize()
+
+	upvalues := make([]upvalue, 0, fn.UpvalueCount)
+	for range fn.UpvalueCount {
+		if offset+2 > len(c.Code) {
+			return upvalues, false
+		}
+		upvalues = append(upvalues, upvalue{isLocal: c.Code[offset] == 1, index: c.Code[offset+1]})
+		offset += 2
+	}
+
+	return upvalues, true
+}
+
+// Function is a compiled Lox function.
+type Function struct {
+	// The name of the function, which is empty for the top-level script.
+	Name string
+
+	Arity        int
+	UpvalueCount int
+	Chunk        Chunk
+}
+
+// String implements the [fmt.Stringer] interface.
+func (fn *Function) String() string {
+	if fn.Name == "" {
+		return "<script>"
+	}
+	return fmt.Sprintf("<fn %s>", fn.Name)
+}
+
+// TypeName implements the [lox.Object] interface.
+func (fn *Function) TypeName() string {
+	return "function"
+}
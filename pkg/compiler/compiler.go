@@ -0,0 +1,608 @@
+package compiler
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/kevhlee/glox/pkg/ast"
+	"github.com/kevhlee/glox/pkg/lox"
+	"github.com/kevhlee/glox/pkg/parser"
+	"github.com/kevhlee/glox/pkg/token"
+)
+
+// The limits of the operands of instructions.
+const (
+	maxConstants = math.MaxUint16 + 1
+	maxLocals    = math.MaxUint8 + 1
+	maxUpvalues  = math.MaxUint8 + 1
+	maxJump      = math.MaxUint16
+)
+
+// The kind of function being compiled.
+type functionType int
+
+const (
+	functionScript functionType = iota
+	functionFunction
+	functionInitializer
+	functionMethod
+)
+
+// A local variable in the function being compiled.
+type local struct {
+	name string
+
+	// The depth of the scope declaring the variable, or -1 if its initializer
+	// has not been compiled yet
+	depth int
+
+	// Whether a closure captures the variable
+	captured bool
+}
+
+// A variable captured by the function being compiled.
+type upvalue struct {
+	// Whether the variable is a local of the enclosing function, as opposed to
+	// one of its upvalues
+	isLocal bool
+	index   byte
+}
+
+// The class being compiled.
+type classCompiler struct {
+	enclosing     *classCompiler
+	hasSuperclass bool
+}
+
+// Contains the internal state and logic of the compiler for a single
+// function.
+type compiler struct {
+	enclosing *compiler
+	fn        *Function
+	kind      functionType
+
+	locals     []local
+	upvalues   []upvalue
+	scopeDepth int
+
+	class *classCompiler
+
+	// The indices of string and number constants, so that they are only added
+	// once to the constant pool
+	constants map[lox.Value]int
+
+	// The last token compiled, used for instructions without a token of their
+	// own
+	last *token.Token
+
+	// Shared by all the compilers of a program
+	errors *parser.ErrorList
+}
+
+func newCompiler(enclosing *compiler, kind functionType, name *token.Token) *compiler {
+	c := &compiler{
+		enclosing: enclosing,
+		fn:        &Function{},
+		kind:      kind,
+		constants: make(map[lox.Value]int),
+		last:      &token.Token{Type: token.EOF, Line: 1},
+	}
+
+	if enclosing != nil {
+		c.class = enclosing.class
+		c.errors = enclosing.errors
+	} else {
+		c.errors = new(parser.ErrorList)
+	}
+
+	if name != nil {
+		c.fn.Name = name.Lexeme
+		c.last = name
+	}
+
+	// The first slot holds the function being called, or the instance in
+	// methods
+	slot := local{depth: 0}
+	if kind == functionMethod || kind == functionInitializer {
+		slot.name = "this"
+	}
+	c.locals = append(c.locals, slot)
+
+	return c
+}
+
+func (c *compiler) error(tok *token.Token, msg string) {
+	*c.errors = append(*c.errors, &parser.Error{Msg: msg, Token: tok})
+}
+
+// Finishes compiling the function.
+func (c *compiler) end() *Function {
+	c.emitReturn()
+	return c.fn
+}
+
+func (c *compiler) compile(node ast.Node) {
+	ast.Walk(c, node)
+}
+
+//
+// Bytecode
+//
+
+func (c *compiler) emit(tok *token.Token, op OpCode, operands ...byte) {
+	c.last = tok
+	c.fn.Chunk.Write(byte(op), tok)
+	for _, operand := range operands {
+		c.fn.Chunk.Write(operand, tok)
+	}
+}
+
+func (c *compiler) emitShort(tok *token.Token, op OpCode, operand int) {
+	c.emit(tok, op, byte(operand>>8), byte(operand))
+}
+
+func (c *compiler) emitReturn() {
+	if c.kind == functionInitializer {
+		c.emit(c.last, OpGetLocal, 0)
+	} else {
+		c.emit(c.last, OpNil)
+	}
+	c.emit(c.last, OpReturn)
+}
+
+// Emits a forward jump and returns the offset of its operand, to be patched
+// once the target is known.
+func (c *compiler) emitJump(tok *token.Token, op OpCode) int {
+	c.emitShort(tok, op, 0xffff)
+	return len(c.fn.Chunk.Code) - 2
+}
+
+func (c *compiler) patchJump(tok *token.Token, offset int) {
+	code := c.fn.Chunk.Code
+
+	jump := len(code) - offset - 2
+	if jump > maxJump {
+		c.error(tok, "Too much code to jump over")
+	}
+
+	code[offset] = byte(jump >> 8)
+	code[offset+1] = byte(jump)
+}
+
+func (c *compiler) emitLoop(tok *token.Token, start int) {
+	// The offset also skips over the operand of the loop instruction
+	jump := len(c.fn.Chunk.Code) - start + 3
+	if jump > maxJump {
+		c.error(tok, "Loop body too large")
+	}
+
+	c.emitShort(tok, OpLoop, jump)
+}
+
+func (c *compiler) makeConstant(tok *token.Token, value lox.Value) int {
+	isDeduplicated := value.Type() == lox.TypeNumber || value.Type() == lox.TypeString
+
+	if isDeduplicated {
+		if index, ok := c.constants[value]; ok {
+			return index
+		}
+	}
+
+	chunk := &c.fn.Chunk
+	if len(chunk.Constants) == maxConstants {
+		c.error(tok, "Too many constants in one chunk")
+		return 0
+	}
+
+	index := len(chunk.Constants)
+	chunk.Constants = append(chunk.Constants, value)
+
+	if isDeduplicated {
+		c.constants[value] = index
+	}
+
+	return index
+}
+
+func (c *compiler) emitConstant(tok *token.Token, value lox.Value) {
+	c.emitShort(tok, OpConstant, c.makeConstant(tok, value))
+}
+
+//
+// Variables
+//
+
+func (c *compiler) beginScope() {
+	c.scopeDepth++
+}
+
+func (c *compiler) endScope() {
+	c.scopeDepth--
+
+	for n := len(c.locals); n > 0 && c.locals[n-1].depth > c.scopeDepth; n-- {
+		if c.locals[n-1].captured {
+			c.emit(c.last, OpCloseUpvalue)
+		} else {
+			c.emit(c.last, OpPop)
+		}
+		c.locals = c.locals[:n-1]
+	}
+}
+
+// Declares a variable in the current scope. Global variables are not
+// declared, since they are bound late.
+func (c *compiler) declareVariable(name *token.Token) {
+	if c.scopeDepth == 0 {
+		return
+	}
+
+	if len(c.locals) == maxLocals {
+		c.error(name, "Too many local variables in function")
+		return
+	}
+
+	c.locals = append(c.locals, local{name: name.Lexeme, depth: -1})
+}
+
+// Marks the most recently declared variable as initialized.
+func (c *compiler) markInitialized() {
+	if c.scopeDepth > 0 {
+		c.locals[len(c.locals)-1].depth = c.scopeDepth
+	}
+}
+
+// Defines a declared variable with the value on top of the stack.
+func (c *compiler) defineVariable(name *token.Token) {
+	if c.scopeDepth > 0 {
+		c.markInitialized()
+		return
+	}
+
+	c.emitShort(name, OpDefineGlobal, c.makeConstant(name, lox.StringValue(name.Lexeme)))
+}
+
+func (c *compiler) resolveLocal(name string) (int, bool) {
+	for i := len(c.locals) - 1; i >= 0; i-- {
+		if c.locals[i].name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (c *compiler) resolveUpvalue(tok *token.Token, name string) (int, bool) {
+	if c.enclosing == nil {
+		return 0, false
+	}
+
+	if slot, ok := c.enclosing.resolveLocal(name); ok {
+		c.enclosing.locals[slot].captured = true
+		return c.addUpvalue(tok, true, slot), true
+	}
+
+	if index, ok := c.enclosing.resolveUpvalue(tok, name); ok {
+		return c.addUpvalue(tok, false, index), true
+	}
+
+	return 0, false
+}
+
+func (c *compiler) addUpvalue(tok *token.Token, isLocal bool, index int) int {
+	for i, uv := range c.upvalues {
+		if uv.isLocal == isLocal && int(uv.index) == index {
+			return i
+		}
+	}
+
+	if len(c.upvalues) == maxUpvalues {
+		c.error(tok, "Too many closure variables in function")
+		return 0
+	}
+
+	c.upvalues = append(c.upvalues, upvalue{isLocal: isLocal, index: byte(index)})
+	c.fn.UpvalueCount = len(c.upvalues)
+	return len(c.upvalues) - 1
+}
+
+// Emits the instruction to read (or assign if value is not nil) a variable.
+// The name of the variable may differ from the lexeme of its token for
+// 'this' and 'super'.
+func (c *compiler) namedVariable(tok *token.Token, name string, value ast.Expr) {
+	var (
+		getOp, setOp OpCode
+		operand      int
+	)
+
+	if slot, ok := c.resolveLocal(name); ok {
+		getOp, setOp, operand = OpGetLocal, OpSetLocal, slot
+	} else if index, ok := c.resolveUpvalue(tok, name); ok {
+		getOp, setOp, operand = OpGetUpvalue, OpSetUpvalue, index
+	} else {
+		c.variableGlobal(tok, name, value)
+		return
+	}
+
+	if value != nil {
+		c.compile(value)
+		c.emit(tok, setOp, byte(operand))
+	} else {
+		c.emit(tok, getOp, byte(operand))
+	}
+}
+
+func (c *compiler) variableGlobal(tok *token.Token, name string, value ast.Expr) {
+	index := c.makeConstant(tok, lox.StringValue(name))
+
+	if value != nil {
+		c.compile(value)
+		c.emitShort(tok, OpSetGlobal, index)
+	} else {
+		c.emitShort(tok, OpGetGlobal, index)
+	}
+}
+
+//
+// Declarations
+//
+
+func (c *compiler) function(stmt *ast.FunctionStmt, kind functionType) {
+	fc := newCompiler(c, kind, stmt.Name)
+	fc.fn.Arity = len(stmt.Params)
+
+	fc.beginScope()
+	for _, param := range stmt.Params {
+		fc.declareVariable(param)
+		fc.defineVariable(param)
+	}
+	for _, b := range stmt.Body {
+		fc.compile(b)
+	}
+	fn := fc.end()
+
+	c.emitShort(stmt.Name, OpClosure, c.makeConstant(stmt.Name, lox.ObjectValue(fn)))
+	for _, uv := range fc.upvalues {
+		isLocal := byte(0)
+		if uv.isLocal {
+			isLocal = 1
+		}
+		c.fn.Chunk.Write(isLocal, stmt.Name)
+		c.fn.Chunk.Write(uv.index, stmt.Name)
+	}
+}
+
+func (c *compiler) classDeclaration(stmt *ast.ClassStmt) {
+	name := stmt.Name
+
+	c.declareVariable(name)
+	c.emitShort(name, OpClass, c.makeConstant(name, lox.StringValue(name.Lexeme)))
+	c.defineVariable(name)
+
+	class := &classCompiler{enclosing: c.class}
+	c.class = class
+
+	defer func() {
+		c.class = class.enclosing
+	}()
+
+	if stmt.Superclass != nil {
+		superclass := stmt.Superclass.Name
+
+		c.namedVariable(superclass, superclass.Lexeme, nil)
+
+		c.beginScope()
+		c.locals = append(c.locals, local{name: "super"})
+		c.markInitialized()
+
+		c.namedVariable(name, name.Lexeme, nil)
+		c.emit(superclass, OpInherit)
+		class.hasSuperclass = true
+	}
+
+	c.namedVariable(name, name.Lexeme, nil)
+
+	for _, method := range stmt.Methods {
+		kind := functionMethod
+		if method.Name.Lexeme == "init" {
+			kind = functionInitializer
+		}
+		c.function(method, kind)
+		c.emitShort(method.Name, OpMethod, c.makeConstant(method.Name, lox.StringValue(method.Name.Lexeme)))
+	}
+
+	c.emit(name, OpPop)
+
+	if class.hasSuperclass {
+		c.endScope()
+	}
+}
+
+// Visit implements the [ast.Visitor] interface.
+func (c *compiler) Visit(node ast.Node) bool {
+	switch node := node.(type) {
+	// Stmt
+
+	case *ast.BlockStmt:
+		c.beginScope()
+		for _, b := range node.Body {
+			c.compile(b)
+		}
+		c.endScope()
+
+	case *ast.ClassStmt:
+		c.classDeclaration(node)
+
+	case *ast.ExpressionStmt:
+		c.compile(node.Expression)
+		c.emit(c.last, OpPop)
+
+	case *ast.FunctionStmt:
+		// Functions can refer to themselves, so they are initialized before
+		// their body is compiled
+		c.declareVariable(node.Name)
+		c.markInitialized()
+		c.function(node, functionFunction)
+		c.defineVariable(node.Name)
+
+	case *ast.IfStmt:
+		c.compile(node.Condition)
+
+		thenJump := c.emitJump(c.last, OpJumpIfFalse)
+		c.emit(c.last, OpPop)
+		c.compile(node.Then)
+
+		elseJump := c.emitJump(c.last, OpJump)
+		c.patchJump(c.last, thenJump)
+		c.emit(c.last, OpPop)
+
+		if node.Else != nil {
+			c.compile(node.Else)
+		}
+		c.patchJump(c.last, elseJump)
+
+	case *ast.PrintStmt:
+		c.compile(node.Value)
+		c.emit(c.last, OpPrint)
+
+	case *ast.ReturnStmt:
+		if node.Value == nil {
+			c.last = node.Keyword
+			c.emitReturn()
+		} else {
+			c.compile(node.Value)
+			c.emit(node.Keyword, OpReturn)
+		}
+
+	case *ast.VarStmt:
+		c.declareVariable(node.Name)
+		if node.Value != nil {
+			c.compile(node.Value)
+		} else {
+			c.emit(node.Name, OpNil)
+		}
+		c.defineVariable(node.Name)
+
+	case *ast.WhileStmt:
+		start := len(c.fn.Chunk.Code)
+		c.compile(node.Condition)
+
+		exitJump := c.emitJump(c.last, OpJumpIfFalse)
+		c.emit(c.last, OpPop)
+		c.compile(node.Body)
+		c.emitLoop(c.last, start)
+
+		c.patchJump(c.last, exitJump)
+		c.emit(c.last, OpPop)
+
+	// Expr
+
+	case *ast.AssignExpr:
+		c.namedVariable(node.Name, node.Name.Lexeme, node.Value)
+
+	case *ast.BinaryExpr:
+		c.compile(node.Left)
+		c.compile(node.Right)
+
+		switch op := node.Operator; op.Type {
+		case token.BANG_EQUAL:
+			c.emit(op, OpEqual)
+			c.emit(op, OpNot)
+		case token.EQUAL_EQUAL:
+			c.emit(op, OpEqual)
+		case token.GREATER:
+			c.emit(op, OpGreater)
+		case token.GREATER_EQUAL:
+			c.emit(op, OpGreaterEqual)
+		case token.LESS:
+			c.emit(op, OpLess)
+		case token.LESS_EQUAL:
+			c.emit(op, OpLessEqual)
+		case token.PLUS:
+			c.emit(op, OpAdd)
+		case token.MINUS:
+			c.emit(op, OpSubtract)
+		case token.STAR:
+			c.emit(op, OpMultiply)
+		case token.SLASH:
+			c.emit(op, OpDivide)
+		}
+
+	case *ast.CallExpr:
+		c.compile(node.Callee)
+		for _, arg := range node.Args {
+			c.compile(arg)
+		}
+		c.emit(node.Paren, OpCall, byte(len(node.Args)))
+
+	case *ast.GetExpr:
+		c.compile(node.Object)
+		c.emitShort(node.Name, OpGetProperty, c.makeConstant(node.Name, lox.StringValue(node.Name.Lexeme)))
+
+	case *ast.GroupingExpr:
+		c.compile(node.Group)
+
+	case *ast.LiteralExpr:
+		switch value := node.Value; value.Type {
+		case token.NIL:
+			c.emit(value, OpNil)
+		case token.TRUE:
+			c.emit(value, OpTrue)
+		case token.FALSE:
+			c.emit(value, OpFalse)
+		case token.STRING:
+			c.emitConstant(value, lox.StringValue(value.Lexeme[1:len(value.Lexeme)-1]))
+		case token.NUMBER:
+			number, err := strconv.ParseFloat(value.Lexeme, 64)
+			if err != nil {
+				c.error(value, "Invalid number literal")
+			}
+			c.emitConstant(value, lox.NumberValue(number))
+		}
+
+	case *ast.LogicalExpr:
+		c.compile(node.Left)
+
+		if node.Operator.Type == token.OR {
+			elseJump := c.emitJump(node.Operator, OpJumpIfFalse)
+			endJump := c.emitJump(node.Operator, OpJump)
+
+			c.patchJump(node.Operator, elseJump)
+			c.emit(node.Operator, OpPop)
+			c.compile(node.Right)
+			c.patchJump(node.Operator, endJump)
+		} else {
+			endJump := c.emitJump(node.Operator, OpJumpIfFalse)
+
+			c.emit(node.Operator, OpPop)
+			c.compile(node.Right)
+			c.patchJump(node.Operator, endJump)
+		}
+
+	case *ast.SetExpr:
+		c.compile(node.Object)
+		c.compile(node.Value)
+		c.emitShort(node.Name, OpSetProperty, c.makeConstant(node.Name, lox.StringValue(node.Name.Lexeme)))
+
+	case *ast.SuperExpr:
+		c.namedVariable(node.Keyword, "this", nil)
+		c.namedVariable(node.Keyword, "super", nil)
+		c.emitShort(node.Method, OpGetSuper, c.makeConstant(node.Method, lox.StringValue(node.Method.Lexeme)))
+
+	case *ast.ThisExpr:
+		c.namedVariable(node.Keyword, "this", nil)
+
+	case *ast.UnaryExpr:
+		c.compile(node.Right)
+
+		switch op := node.Operator; op.Type {
+		case token.BANG:
+			c.emit(op, OpNot)
+		case token.MINUS:
+			c.emit(op, OpNegate)
+		}
+
+	case *ast.VariableExpr:
+		c.namedVariable(node.Name, node.Name.Lexeme, nil)
+	}
+
+	return false
+}
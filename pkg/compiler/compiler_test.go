@@ -0,0 +1,255 @@
+package compiler_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kevhlee/glox/pkg/compiler"
+	"github.com/kevhlee/glox/pkg/lox"
+	"github.com/kevhlee/glox/pkg/parser"
+)
+
+// TestCompile checks that statements are compiled into the expected bytecode.
+func TestCompile(t *testing.T) {
+	fn := testCompile(t, "print 1 + 2;\nvar a = 1;")
+
+	expectedCode := []byte{
+		byte(compiler.OpConstant), 0, 0,
+		byte(compiler.OpConstant), 0, 1,
+		byte(compiler.OpAdd),
+		byte(compiler.OpPrint),
+		byte(compiler.OpConstant), 0, 0,
+		byte(compiler.OpDefineGlobal), 0, 2,
+		byte(compiler.OpNil),
+		byte(compiler.OpReturn),
+	}
+	if !slices.Equal(fn.Chunk.Code, expectedCode) {
+		t.Errorf("Expected code %v but got %v", expectedCode, fn.Chunk.Code)
+	}
+
+	expectedConstants := []lox.Value{lox.NumberValue(1), lox.NumberValue(2), lox.StringValue("a")}
+	if !slices.Equal(fn.Chunk.Constants, expectedConstants) {
+		t.Errorf("Expected constants %v but got %v", expectedConstants, fn.Chunk.Constants)
+	}
+
+	expectedLines := []int{1, 1, 1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2}
+	if !slices.Equal(fn.Chunk.Lines, expectedLines) {
+		t.Errorf("Expected lines %v but got %v", expectedLines, fn.Chunk.Lines)
+	}
+}
+
+// TestCompileFunction checks that functions are compiled into constants with
+// their arity and captured variables.
+func TestCompileFunction(t *testing.T) {
+	fn := testCompile(t, "fun outer(a, b) { fun inner() { return a; } }")
+
+	outer, ok := fn.Chunk.Constants[0].AsObject().(*compiler.Function)
+	if !ok || outer.Name != "outer" || outer.Arity != 2 {
+		t.Fatalf("Expected function outer/2 but got %v", fn.Chunk.Constants[0])
+	}
+
+	inner, ok := outer.Chunk.Constants[0].AsObject().(*compiler.Function)
+	if !ok || inner.Name != "inner" || inner.UpvalueCount != 1 {
+		t.Fatalf("Expected function inner with 1 upvalue but got %v", outer.Chunk.Constants[0])
+	}
+}
+
+// TestCompileLimits checks that exceeding the limits of the bytecode is
+// reported as a compile error.
+func TestCompileLimits(t *testing.T) {
+	var sb strings.Builder
+
+	sb.WriteString("{\n")
+	for i := range 300 {
+		fmt.Fprintf(&sb, "var v%d;\n", i)
+	}
+	sb.WriteString("}\n")
+
+	body, err := parser.ParseSource(sb.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = compiler.Compile(body)
+
+	errs, ok := err.(parser.ErrorList)
+	if !ok || len(errs) == 0 {
+		t.Fatalf("Expected compile errors but got %v", err)
+	}
+	if expected := "Too many local variables in function"; errs[0].Msg != expected {
+		t.Errorf("Expected error %q but got %q", expected, errs[0].Msg)
+	}
+}
+
+// TestDisassemble checks that the disassembler lists instructions with their
+// offsets, lines, operands and constants.
+func TestDisassemble(t *testing.T) {
+	fn := testCompile(t, `fun f(a) {
+  fun g() { return a; }
+}
+while (true) print "x";
+`)
+
+	expected := `== <script> ==
+0000    1 OP_CLOSURE          0 <fn f>
+0003    | OP_DEFINE_GLOBAL    1 'f'
+0006    4 OP_TRUE
+0007    | OP_JUMP_IF_FALSE    7 -> 18
+0010    | OP_POP
+0011    | OP_CONSTANT         2 'x'
+0014    | OP_PRINT
+0015    | OP_LOOP            15 -> 6
+0018    | OP_POP
+0019    | OP_NIL
+0020    | OP_RETURN
+
+== <fn f> ==
+0000    2 OP_CLOSURE          0 <fn g>
+0003    |                     1 local
+0005    | OP_NIL
+0006    | OP_RETURN
+
+== <fn g> ==
+0000    2 OP_GET_UPVALUE      0
+0002    | OP_RETURN
+0003    | OP_NIL
+0004    | OP_RETURN
+`
+
+	if actual := compiler.Disassemble(fn); actual != expected {
+		t.Errorf("\nExpected:\n%s\nActual:\n%s\n", expected, actual)
+	}
+}
+
+// TestDisassembleShared checks that functions referred to more than once, or by
+// themselves, are only listed once.
+func TestDisassembleShared(t *testing.T) {
+	code := []byte{byte(compiler.OpNil), byte(compiler.OpReturn)}
+
+	inner := &compiler.Function{Name: "f", Chunk: compiler.Chunk{Code: code, Lines: []int{1, 1}}}
+	inner.Chunk.Constants = []lox.Value{lox.ObjectValue(inner)}
+	script := &compiler.Function{Chunk: compiler.Chunk{Code: code, Lines: []int{1, 1}}}
+	script.Chunk.Constants = []lox.Value{lox.ObjectValue(inner), lox.ObjectValue(inner)}
+
+	expected := `== <script> ==
+0000    1 OP_NIL
+0001    | OP_RETURN
+
+== <fn f> ==
+0000    1 OP_NIL
+0001    | OP_RETURN
+`
+
+	if actual := compiler.Disassemble(script); actual != expected {
+		t.Errorf("\nExpected:\n%s\nActual:\n%s\n", expected, actual)
+	}
+}
+
+// TestEncode checks that a compiled script is unchanged after being encoded
+// and decoded.
+func TestEncode(t *testing.T) {
+	fn := testCompile(t, `fun makeCounter() {
+  var i = 0;
+  fun count() { i = i + 1; return i; }
+  return count;
+}
+class A < B { m() { return super.m() + "s" + 1.5; } }
+`)
+
+	decoded, err := compiler.Decode(compiler.Encode(fn))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected, actual := compiler.Disassemble(fn), compiler.Disassemble(decoded); actual != expected {
+		t.Errorf("\nExpected:\n%s\nActual:\n%s\n", expected, actual)
+	}
+}
+
+// TestDecodeErrors checks that invalid compiled files are rejected.
+func TestDecodeErrors(t *testing.T) {
+	data := compiler.Encode(testCompile(t, "print 1;"))
+
+	corrupted := slices.Clone(data)
+	corrupted[len(corrupted)/2] ^= 0xff
+
+	version := slices.Clone(data)
+	version[5]++
+
+	const (
+		opNil         = byte(compiler.OpNil)
+		opPop         = byte(compiler.OpPop)
+		opGetLocal    = byte(compiler.OpGetLocal)
+		opSetLocal    = byte(compiler.OpSetLocal)
+		opJump        = byte(compiler.OpJump)
+		opJumpIfFalse = byte(compiler.OpJumpIfFalse)
+		opLoop        = byte(compiler.OpLoop)
+		opCall        = byte(compiler.OpCall)
+		opReturn      = byte(compiler.OpReturn)
+	)
+
+	withParams := &compiler.Function{Arity: 1, Chunk: compiler.Chunk{Code: []byte{opNil, opReturn}, Lines: []int{1, 1}}}
+
+	// Functions that refer to themselves, or that are referred to twice
+	inner := &compiler.Function{Name: "f", Chunk: compiler.Chunk{Code: []byte{opNil, opReturn}, Lines: []int{1, 1}}}
+	cyclic := &compiler.Function{Chunk: compiler.Chunk{Code: []byte{opNil, opReturn}, Lines: []int{1, 1}}}
+	cyclic.Chunk.Constants = []lox.Value{lox.ObjectValue(inner)}
+	inner.Chunk.Constants = []lox.Value{lox.ObjectValue(inner)}
+	shared := &compiler.Function{Chunk: compiler.Chunk{Code: []byte{opNil, opReturn}, Lines: []int{1, 1}}}
+	shared.Chunk.Constants = []lox.Value{lox.ObjectValue(withParams), lox.ObjectValue(withParams)}
+
+	tests := []struct {
+		data     []byte
+		expected string
+	}{
+		{[]byte("print 1;"), "not a compiled Lox file"},
+		{version, fmt.Sprintf("unsupported bytecode version %d (expected %d)", compiler.FormatVersion+1, compiler.FormatVersion)},
+		{corrupted, "file is corrupted (checksum mismatch)"},
+		{data[:len(data)-1], "file is corrupted (checksum mismatch)"},
+		{data[:6], "file is truncated"},
+		{encodeScript(0xff), "<script>: invalid opcode 255 at offset 0"},
+		{encodeScript(opGetLocal), "<script>: truncated instruction at offset 0"},
+		{encodeScript(opNil), "<script>: code does not end with a return"},
+		{encodeScript(opGetLocal, 200, opReturn), "<script>: invalid local slot at offset 0"},
+		{encodeScript(opNil, opSetLocal, 2, opReturn), "<script>: invalid local slot at offset 1"},
+		{encodeScript(opPop, opPop, opNil, opReturn), "<script>: stack underflow at offset 1"},
+		{encodeScript(opNil, opCall, 2, opReturn), "<script>: stack underflow at offset 1"},
+		{encodeScript(opJump, 0, 1, opGetLocal, 0, opReturn), "<script>: invalid jump at offset 0"},
+		{encodeScript(opJump, 0, 5, opReturn), "<script>: invalid jump at offset 0"},
+		{encodeScript(opGetLocal, 0, opLoop, 0, 4), "<script>: invalid jump at offset 2"},
+		{encodeScript(opNil, opJumpIfFalse, 0, 1, opNil, opReturn), "<script>: inconsistent stack depth at offset 5"},
+		{compiler.Encode(withParams), "<script>: script cannot have parameters or upvalues"},
+		{compiler.Encode(cyclic), "invalid function index 1"},
+		{compiler.Encode(shared), "function 1 is referenced more than once"},
+	}
+
+	for _, test := range tests {
+		_, err := compiler.Decode(test.data)
+		if _, ok := err.(*compiler.DecodeError); !ok || err.Error() != test.expected {
+			t.Errorf("Expected error %q but got %v", test.expected, err)
+		}
+	}
+}
+
+// Encodes a script made of the given code.
+func encodeScript(code ...byte) []byte {
+	return compiler.Encode(&compiler.Function{Chunk: compiler.Chunk{Code: code, Lines: make([]int, len(code))}})
+}
+
+func testCompile(t *testing.T, source string) *compiler.Function {
+	t.Helper()
+
+	body, err := parser.ParseSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fn, err := compiler.Compile(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fn
+}
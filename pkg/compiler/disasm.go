@@ -0,0 +1,127 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kevhlee/glox/pkg/lox"
+)
+
+// Contains the internal state and logic of the bytecode disassembler.
+type disassembler struct {
+	strings.Builder
+
+	// The functions already listed, so that each is listed once even if it is
+	// referred to more than once
+	listed map[*Function]bool
+}
+
+// Writes the listing of a function, followed by the listings of the functions
+// in its constant pool.
+func (d *disassembler) function(fn *Function) {
+	if d.listed[fn] {
+		return
+	}
+	d.listed[fn] = true
+
+	fmt.Fprintf(d, "== %s ==\n", fn)
+
+	for offset := 0; offset < len(fn.Chunk.Code); {
+		offset = d.instruction(&fn.Chunk, offset)
+	}
+
+	for _, constant := range fn.Chunk.Constants {
+		if inner, ok := constant.AsObject().(*Function); ok && !d.listed[inner] {
+			d.WriteString("\n")
+			d.function(inner)
+		}
+	}
+}
+
+// Writes the instruction at an offset and returns the offset of the next one.
+func (d *disassembler) instruction(chunk *Chunk, offset int) int {
+	fmt.Fprintf(d, "%04d ", offset)
+
+	// Like clox, a line is only shown when it changes
+	if offset > 0 && chunk.Lines[offset] == chunk.Lines[offset-1] {
+		d.WriteString("   | ")
+	} else {
+		fmt.Fprintf(d, "%4d ", chunk.Lines[offset])
+	}
+
+	op := OpCode(chunk.Code[offset])
+
+	size := 1 + op.operandSize()
+	if offset+size > len(chunk.Code) {
+		fmt.Fprintf(d, "%-16s <truncated>\n", op)
+		return len(chunk.Code)
+	}
+
+	switch op.operandSize() {
+	case 1:
+		fmt.Fprintf(d, "%-16s %4d\n", op, chunk.Code[offset+1])
+
+	case 2:
+		operand := chunk.ReadShort(offset + 1)
+
+		switch op {
+		case OpJump, OpJumpIfFalse:
+			fmt.Fprintf(d, "%-16s %4d -> %d\n", op, offset, offset+3+operand)
+		case OpLoop:
+			fmt.Fprintf(d, "%-16s %4d -> %d\n", op, offset, offset+3-operand)
+		case OpClosure:
+			return d.closure(chunk, offset, operand)
+		default:
+			fmt.Fprintf(d, "%-16s %4d %s\n", op, operand, formatConstant(chunk, operand))
+		}
+
+	default:
+		fmt.Fprintf(d, "%s\n", op)
+	}
+
+	return offset + size
+}
+
+// Writes a closure instruction, followed by a line for each variable it
+// captures.
+func (d *disassembler) closure(chunk *Chunk, offset, index int) int {
+	fn, ok := constantAt(chunk, index).AsObject().(*Function)
+	if !ok {
+		fmt.Fprintf(d, "%-16s %4d <invalid>\n", OpClosure, index)
+		return offset + 1 + OpClosure.operandSize()
+	}
+	fmt.Fprintf(d, "%-16s %4d %s\n", OpClosure, index, fn)
+
+	upvalues, ok := chunk.readUpvalues(offset, fn)
+	offset += 1 + OpClosure.operandSize()
+
+	for _, uv := range upvalues {
+		kind := "upvalue"
+		if uv.isLocal {
+			kind = "local"
+		}
+		fmt.Fprintf(d, "%04d    | %-16s %4d %s\n", offset, "", uv.index, kind)
+		offset += 2
+	}
+
+	if !ok {
+		fmt.Fprintf(d, "%04d    | %-16s <truncated>\n", offset, "")
+		return len(chunk.Code)
+	}
+
+	return offset
+}
+
+func constantAt(chunk *Chunk, index int) lox.Value {
+	if index < len(chunk.Constants) {
+		return chunk.Constants[index]
+	}
+	return lox.Value{}
+}
+
+func formatConstant(chunk *Chunk, index int) string {
+	if index >= len(chunk.Constants) {
+		return "<invalid>"
+	}
+	return fmt.Sprintf("'%v'", chunk.Constants[index])
+}
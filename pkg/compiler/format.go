@@ -0,0 +1,460 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+
+	"github.com/kevhlee/glox/pkg/lox"
+)
+
+// The layout of a compiled file, where all integers are big-endian:
+//
+//	magic     [4]byte  "LOXC"
+//	version   uint16   FormatVersion
+//	strings   uint32 count, then per string: uint32 length, bytes
+//	functions uint32 count, then per function (the script first, and every
+//	          other function once, after the function that refers to it):
+//	  name      uint32 string index
+//	  arity     uint16
+//	  upvalues  uint16
+//	  constants uint32 count, then per constant: tag byte, then
+//	            float64 bits (number), uint32 string index (string) or
+//	            uint32 function index (function)
+//	  code      uint32 length, bytes
+//	  lines     uint32 count, then per run of bytes on the same line:
+//	            uint32 line, uint32 length
+//	checksum  uint32   CRC-32 (IEEE) of everything before it
+const magic = "LOXC"
+
+// The tags of constants in a compiled file.
+const (
+	tagNumber byte = iota + 1
+	tagString
+	tagFunction
+)
+
+// Contains the internal state and logic of the bytecode encoder.
+type encoder struct {
+	strings     []string
+	stringIndex map[string]int
+
+	functions     []*Function
+	functionIndex map[*Function]int
+}
+
+func (e *encoder) addString(s string) int {
+	if index, ok := e.stringIndex[s]; ok {
+		return index
+	}
+
+	e.stringIndex[s] = len(e.strings)
+	e.strings = append(e.strings, s)
+	return len(e.strings) - 1
+}
+
+// Lists a function and the functions in its constant pool, so that each is
+// given an index.
+func (e *encoder) addFunction(fn *Function) {
+	if _, ok := e.functionIndex[fn]; ok {
+		return
+	}
+
+	e.functionIndex[fn] = len(e.functions)
+	e.functions = append(e.functions, fn)
+
+	e.addString(fn.Name)
+	for _, constant := range fn.Chunk.Constants {
+		switch constant.Type() {
+		case lox.TypeString:
+			e.addString(constant.AsString())
+		case lox.TypeObject:
+			e.addFunction(constant.AsObject().(*Function))
+		}
+	}
+}
+
+func (e *encoder) encode(script *Function) []byte {
+	e.addFunction(script)
+
+	var buf bytes.Buffer
+
+	buf.WriteString(magic)
+	buf.Write(binary.BigEndian.AppendUint16(nil, FormatVersion))
+
+	writeUint32(&buf, len(e.strings))
+	for _, s := range e.strings {
+		writeUint32(&buf, len(s))
+		buf.WriteString(s)
+	}
+
+	writeUint32(&buf, len(e.functions))
+	for _, fn := range e.functions {
+		e.encodeFunction(&buf, fn)
+	}
+
+	buf.Write(binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(buf.Bytes())))
+
+	return buf.Bytes()
+}
+
+func (e *encoder) encodeFunction(buf *bytes.Buffer, fn *Function) {
+	writeUint32(buf, e.stringIndex[fn.Name])
+	buf.Write(binary.BigEndian.AppendUint16(nil, uint16(fn.Arity)))
+	buf.Write(binary.BigEndian.AppendUint16(nil, uint16(fn.UpvalueCount)))
+
+	chunk := &fn.Chunk
+
+	writeUint32(buf, len(chunk.Constants))
+	for _, constant := range chunk.Constants {
+		switch constant.Type() {
+		case lox.TypeNumber:
+			buf.WriteByte(tagNumber)
+			buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(constant.AsNumber())))
+		case lox.TypeString:
+			buf.WriteByte(tagString)
+			writeUint32(buf, e.stringIndex[constant.AsString()])
+		case lox.TypeObject:
+			buf.WriteByte(tagFunction)
+			writeUint32(buf, e.functionIndex[constant.AsObject().(*Function)])
+		}
+	}
+
+	writeUint32(buf, len(chunk.Code))
+	buf.Write(chunk.Code)
+
+	// Lines are run-length encoded, since consecutive bytes of code are
+	// usually on the same line
+	var runs [][2]int
+	for i, line := range chunk.Lines {
+		if i > 0 && line == chunk.Lines[i-1] {
+			runs[len(runs)-1][1]++
+		} else {
+			runs = append(runs, [2]int{line, 1})
+		}
+	}
+
+	writeUint32(buf, len(runs))
+	for _, run := range runs {
+		writeUint32(buf, run[0])
+		writeUint32(buf, run[1])
+	}
+}
+
+func writeUint32(buf *bytes.Buffer, n int) {
+	buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+}
+
+// Contains the internal state and logic of the bytecode decoder.
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+// Aborts decoding with an error, which is recovered by [Decode].
+func (d *decoder) fail(format string, args ...any) {
+	panic(&DecodeError{Msg: fmt.Sprintf(format, args...)})
+}
+
+func (d *decoder) read(n int) []byte {
+	if n < 0 || len(d.data)-d.pos < n {
+		d.fail("file is truncated")
+	}
+
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *decoder) readByte() byte {
+	return d.read(1)[0]
+}
+
+func (d *decoder) readUint16() int {
+	return int(binary.BigEndian.Uint16(d.read(2)))
+}
+
+func (d *decoder) readUint32() int {
+	return int(binary.BigEndian.Uint32(d.read(4)))
+}
+
+// Reads the number of elements in a section, each taking at least a given
+// number of bytes, so that corrupted counts cannot cause huge allocations.
+func (d *decoder) readCount(size int) int {
+	n := d.readUint32()
+	if n > (len(d.data)-d.pos)/size {
+		d.fail("file is truncated")
+	}
+	return n
+}
+
+func (d *decoder) decode() *Function {
+	if len(d.data) < len(magic) || string(d.data[:len(magic)]) != magic {
+		d.fail("not a compiled Lox file")
+	}
+	d.pos = len(magic)
+
+	if version := d.readUint16(); version != FormatVersion {
+		d.fail("unsupported bytecode version %d (expected %d)", version, FormatVersion)
+	}
+
+	// The checksum is verified before anything else is decoded, so that
+	// corruption is reported as such
+	if len(d.data) < d.pos+4 {
+		d.fail("file is truncated")
+	}
+	end := len(d.data) - 4
+	if crc32.ChecksumIEEE(d.data[:end]) != binary.BigEndian.Uint32(d.data[end:]) {
+		d.fail("file is corrupted (checksum mismatch)")
+	}
+	d.data = d.data[:end]
+
+	strs := make([]string, d.readCount(4))
+	for i := range strs {
+		strs[i] = string(d.read(d.readUint32()))
+	}
+
+	functions := make([]*Function, d.readCount(1))
+	if len(functions) == 0 {
+		d.fail("file has no script")
+	}
+	for i := range functions {
+		functions[i] = &Function{}
+	}
+
+	readString := func() string {
+		index := d.readUint32()
+		if index >= len(strs) {
+			d.fail("invalid string index %d", index)
+		}
+		return strs[index]
+	}
+
+	// Each function is listed after the function that refers to it, and is
+	// referred to only once, so that functions form a tree
+	referenced := make([]bool, len(functions))
+
+	for i, fn := range functions {
+		fn.Name = readString()
+		fn.Arity = d.readUint16()
+		fn.UpvalueCount = d.readUint16()
+
+		chunk := &fn.Chunk
+
+		chunk.Constants = make([]lox.Value, d.readCount(1))
+		for j := range chunk.Constants {
+			switch tag := d.readByte(); tag {
+			case tagNumber:
+				chunk.Constants[j] = lox.NumberValue(math.Float64frombits(binary.BigEndian.Uint64(d.read(8))))
+			case tagString:
+				chunk.Constants[j] = lox.StringValue(readString())
+			case tagFunction:
+				index := d.readUint32()
+				if index <= i || index >= len(functions) {
+					d.fail("invalid function index %d", index)
+				}
+				if referenced[index] {
+					d.fail("function %d is referenced more than once", index)
+				}
+				referenced[index] = true
+				chunk.Constants[j] = lox.ObjectValue(functions[index])
+			default:
+				d.fail("invalid constant tag %d", tag)
+			}
+		}
+
+		chunk.Code = bytes.Clone(d.read(d.readUint32()))
+
+		chunk.Lines = make([]int, 0, len(chunk.Code))
+		for range d.readCount(8) {
+			line, n := d.readUint32(), d.readUint32()
+			if n > len(chunk.Code)-len(chunk.Lines) {
+				d.fail("line table does not match code")
+			}
+			for range n {
+				chunk.Lines = append(chunk.Lines, line)
+			}
+		}
+		if len(chunk.Lines) != len(chunk.Code) {
+			d.fail("line table does not match code")
+		}
+	}
+
+	if d.pos != len(d.data) {
+		d.fail("file has trailing data")
+	}
+
+	// The script is run without arguments or captured variables
+	if script := functions[0]; script.Arity != 0 || script.UpvalueCount != 0 {
+		d.fail("%s: script cannot have parameters or upvalues", script)
+	}
+
+	// Closures are verified against the functions they create, so every
+	// function must be decoded first
+	for _, fn := range functions {
+		d.verify(fn)
+	}
+
+	return functions[0]
+}
+
+// Checks that the instructions of a function and their operands are well
+// formed, and that the stack stays within the function's frame, so that the
+// virtual machine can execute them safely.
+func (d *decoder) verify(fn *Function) {
+	chunk := &fn.Chunk
+
+	constant := func(offset int) lox.Value {
+		index := chunk.ReadShort(offset)
+		if index >= len(chunk.Constants) {
+			d.fail("%s: invalid constant index %d at offset %d", fn, index, offset)
+		}
+		return chunk.Constants[index]
+	}
+
+	if fn.UpvalueCount > maxUpvalues {
+		d.fail("%s: too many upvalues", fn)
+	}
+
+	// The size of the instruction starting at each offset, or 0 if no
+	// instruction starts there
+	sizes := make([]int, len(chunk.Code))
+
+	for offset := 0; offset < len(chunk.Code); {
+		op := OpCode(chunk.Code[offset])
+
+		if _, ok := opNames[op]; !ok {
+			d.fail("%s: invalid opcode %d at offset %d", fn, op, offset)
+		}
+
+		size := 1 + op.operandSize()
+
+		if offset+size > len(chunk.Code) {
+			d.fail("%s: truncated instruction at offset %d", fn, offset)
+		}
+
+		switch op {
+		case OpConstant:
+			if constant(offset+1).Type() == lox.TypeObject {
+				d.fail("%s: invalid constant at offset %d", fn, offset)
+			}
+		case OpGetGlobal, OpDefineGlobal, OpSetGlobal, OpGetProperty, OpSetProperty, OpGetSuper, OpClass, OpMethod:
+			if constant(offset+1).Type() != lox.TypeString {
+				d.fail("%s: invalid name constant at offset %d", fn, offset)
+			}
+		case OpGetUpvalue, OpSetUpvalue:
+			if int(chunk.Code[offset+1]) >= fn.UpvalueCount {
+				d.fail("%s: invalid upvalue index at offset %d", fn, offset)
+			}
+		case OpClosure:
+			inner, ok := constant(offset + 1).AsObject().(*Function)
+			if !ok {
+				d.fail("%s: invalid function constant at offset %d", fn, offset)
+			}
+
+			upvalues, ok := chunk.readUpvalues(offset, inner)
+			if !ok {
+				d.fail("%s: truncated instruction at offset %d", fn, offset)
+			}
+			for _, uv := range upvalues {
+				if !uv.isLocal && int(uv.index) >= fn.UpvalueCount {
+					d.fail("%s: invalid upvalue index at offset %d", fn, offset)
+				}
+			}
+			size += 2 * len(upvalues)
+		}
+
+		sizes[offset] = size
+		offset += size
+	}
+
+	d.verifyStack(fn, sizes)
+}
+
+// Checks that every path through the code of a function keeps the stack within
+// the function's frame, given the size of each instruction.
+//
+// The depth of the stack is tracked relative to the base of the frame, which
+// holds the callee followed by the arguments. Every path must reach a return,
+// and must arrive at each instruction with the same depth.
+func (d *decoder) verifyStack(fn *Function, sizes []int) {
+	chunk := &fn.Chunk
+
+	// The depth of the stack before each instruction, or -1 if the instruction
+	// has not been reached yet
+	depths := make([]int, len(chunk.Code))
+	for i := range depths {
+		depths[i] = -1
+	}
+
+	var pending []int
+
+	reach := func(offset, depth int) {
+		switch {
+		case offset >= len(chunk.Code):
+			d.fail("%s: code does not end with a return", fn)
+		case depths[offset] < 0:
+			depths[offset] = depth
+			pending = append(pending, offset)
+		case depths[offset] != depth:
+			d.fail("%s: inconsistent stack depth at offset %d", fn, offset)
+		}
+	}
+
+	// Jumps must land on the start of an instruction
+	jump := func(from, offset, depth int) {
+		if offset < 0 || offset >= len(chunk.Code) || sizes[offset] == 0 {
+			d.fail("%s: invalid jump at offset %d", fn, from)
+		}
+		reach(offset, depth)
+	}
+
+	reach(0, 1+fn.Arity)
+
+	for len(pending) > 0 {
+		offset := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		depth := depths[offset]
+		op := OpCode(chunk.Code[offset])
+		pops, pushes := op.stackEffect()
+
+		switch op {
+		case OpGetLocal, OpSetLocal:
+			if int(chunk.Code[offset+1]) >= depth {
+				d.fail("%s: invalid local slot at offset %d", fn, offset)
+			}
+		case OpCall:
+			pops += int(chunk.Code[offset+1])
+		case OpClosure:
+			upvalues, _ := chunk.readUpvalues(offset, chunk.Constants[chunk.ReadShort(offset+1)].AsObject().(*Function))
+			for _, uv := range upvalues {
+				if uv.isLocal && int(uv.index) >= depth {
+					d.fail("%s: invalid local slot at offset %d", fn, offset)
+				}
+			}
+		}
+
+		if pops > depth {
+			d.fail("%s: stack underflow at offset %d", fn, offset)
+		}
+		depth += pushes - pops
+
+		next := offset + sizes[offset]
+
+		switch op {
+		case OpReturn:
+		case OpJump:
+			jump(offset, next+chunk.ReadShort(offset+1), depth)
+		case OpJumpIfFalse:
+			jump(offset, next+chunk.ReadShort(offset+1), depth)
+			reach(next, depth)
+		case OpLoop:
+			jump(offset, next-chunk.ReadShort(offset+1), depth)
+		default:
+			reach(next, depth)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Package compiler implements a compiler for a Lox AST into bytecode.
+package compiler
+
+import "github.com/kevhlee/glox/pkg/ast"
+
+// FormatVersion is the version of the format of compiled files written by
+// [Encode]. It changes whenever the format or the instruction set changes.
+const FormatVersion = 1
+
+// Compile converts an AST into the bytecode of a function that executes it as
+// the top-level script.
+//
+// The AST is expected to be resolved without errors. Errors caused by limits of
+// the bytecode (e.g. too many local variables in a function) are returned as a
+// [parser.ErrorList].
+func Compile(body []ast.Stmt) (*Function, error) {
+	c := newCompiler(nil, functionScript, nil)
+
+	for _, stmt := range body {
+		c.compile(stmt)
+	}
+
+	return c.end(), c.errors.Err()
+}
+
+// Disassemble returns a human-readable listing of the bytecode of a function
+// and of the functions it contains.
+//
+// Each instruction is listed with its offset, source line (or "|" if it is the
+// same as the previous instruction's), operands and the values of the
+// constants it refers to.
+func Disassemble(fn *Function) string {
+	d := disassembler{listed: make(map[*Function]bool)}
+	d.function(fn)
+	return d.String()
+}
+
+// Encode converts a compiled script into the contents of a compiled file.
+//
+// Compiled files contain the code, constants and lines of each function, but
+// not the tokens of the source code.
+func Encode(script *Function) []byte {
+	e := encoder{
+		stringIndex:   make(map[string]int),
+		functionIndex: make(map[*Function]int),
+	}
+	return e.encode(script)
+}
+
+// Decode converts the contents of a compiled file written by [Encode] back
+// into a compiled script.
+//
+// A [*DecodeError] is returned if the file is not a compiled file, was written
+// with a different [FormatVersion], or is corrupted.
+func Decode(data []byte) (script *Function, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if r, ok := r.(*DecodeError); ok {
+				err = r
+			} else {
+				panic(r)
+			}
+		}
+	}()
+
+	d := decoder{data: data}
+	return d.decode(), nil
+}
+
+// DecodeError is an error decoding a compiled file.
+type DecodeError struct {
+	Msg string
+}
+
+// Error implements the [error] interface.
+func (err *DecodeError) Error() string {
+	return err.Msg
+}
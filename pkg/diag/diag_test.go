@@ -0,0 +1,64 @@
+package diag_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kevhlee/glox/pkg/diag"
+)
+
+// TestRender checks that diagnostics are rendered with an underlined snippet
+// of the source code, notes and hints.
+func TestRender(t *testing.T) {
+	source := "var a = 1;\n\tprint a +  \"b\nc\";\n"
+
+	testRender(t, source, &diag.Diagnostic{
+		Message: "Error: Mismatched operands.",
+		Span:    diag.Span{Line: 2, Column: 13, Start: 23, End: 28},
+		Notes:   []string{"Strings can only be added to strings."},
+		Hints:   []string{"Convert the number to a string."},
+	}, `Error: Mismatched operands.
+  |
+2 | 	print a +  "b
+  | 	           ^^
+  = note: Strings can only be added to strings.
+  = hint: Convert the number to a string.
+`)
+
+	testRender(t, source, &diag.Diagnostic{
+		Message: "Unknown column.\n[line 1]",
+		Span:    diag.Span{Line: 1},
+	}, `Unknown column.
+[line 1]
+  |
+1 | var a = 1;
+`)
+
+	testRender(t, source, &diag.Diagnostic{
+		Message: "Unknown line.",
+	}, `Unknown line.
+`)
+}
+
+// TestRenderColor checks that colour can be enabled in rendered diagnostics.
+func TestRenderColor(t *testing.T) {
+	var sb strings.Builder
+
+	r := diag.NewRenderer(&sb, "x")
+	r.SetColor(true)
+	r.Render(&diag.Diagnostic{Message: "Error.", Span: diag.Span{Line: 1, Column: 1, Start: 0, End: 1}})
+
+	if actual := sb.String(); !strings.Contains(actual, "\x1b[") {
+		t.Errorf("Expected colour escape codes in '%q'", actual)
+	}
+}
+
+func testRender(t *testing.T, source string, d *diag.Diagnostic, expected string) {
+	var sb strings.Builder
+
+	diag.NewRenderer(&sb, source).Render(d)
+
+	if actual := sb.String(); actual != expected {
+		t.Errorf("\nExpected:\n%s\nActual:\n%s\n", expected, actual)
+	}
+}
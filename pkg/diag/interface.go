@@ -0,0 +1,88 @@
+// Package diag implements a renderer for diagnostics about Lox source code.
+package diag
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// Span is a span of source code that a diagnostic refers to.
+type Span struct {
+	// The line and column (both starting from 1) of the span's first
+	// character. A zero line or column means it is unknown.
+	Line   int
+	Column int
+
+	// The byte offsets of the span in the source code.
+	Start int
+	End   int
+}
+
+// Diagnostic is a message about a span of source code.
+type Diagnostic struct {
+	// The message of the diagnostic, which may span multiple lines.
+	Message string
+
+	// The span of source code the diagnostic refers to.
+	Span Span
+
+	// Additional context about the diagnostic.
+	Notes []string
+
+	// Suggestions on how to fix the diagnostic.
+	Hints []string
+}
+
+// Renderer writes diagnostics with snippets of the source code they refer to.
+type Renderer struct {
+	w      io.Writer
+	source string
+	lines  []string
+	color  bool
+}
+
+// NewRenderer creates a new renderer for diagnostics about the given source
+// code.
+//
+// Colour is enabled if the writer is a terminal and the NO_COLOR environment
+// variable is not set. If the source code is empty (e.g. because it is not
+// available), diagnostics are rendered without snippets.
+func NewRenderer(w io.Writer, source string) *Renderer {
+	r := &Renderer{
+		w:      w,
+		source: source,
+		color:  isTerminal(w) && os.Getenv("NO_COLOR") == "",
+	}
+
+	if source != "" {
+		r.lines = strings.Split(source, "\n")
+	}
+
+	return r
+}
+
+// SetColor enables or disables colour in the rendered diagnostics.
+func (r *Renderer) SetColor(enabled bool) {
+	r.color = enabled
+}
+
+// Render writes a diagnostic.
+//
+// The message is written first, followed by the line of source code the
+// diagnostic refers to with its span underlined, if the span is known. Any
+// notes and hints are written last.
+func (r *Renderer) Render(d *Diagnostic) {
+	r.render(d)
+}
+
+// Checks if a writer is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
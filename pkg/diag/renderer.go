@@ -0,0 +1,86 @@
+package diag
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ANSI escape codes used to colour diagnostics.
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiBlue  = "\x1b[34m"
+)
+
+func (r *Renderer) paint(s, codes string) string {
+	if !r.color || s == "" {
+		return s
+	}
+	return codes + s + ansiReset
+}
+
+func (r *Renderer) render(d *Diagnostic) {
+	var sb strings.Builder
+
+	sb.WriteString(r.paint(strings.TrimSuffix(d.Message, "\n"), ansiBold+ansiRed))
+	sb.WriteString("\n")
+
+	gutter := 1
+	if d.Span.Line > 0 && d.Span.Line <= len(r.lines) {
+		gutter = len(strconv.Itoa(d.Span.Line))
+		r.renderSnippet(&sb, d.Span, gutter)
+	}
+
+	for _, note := range d.Notes {
+		fmt.Fprintf(&sb, "%s %s %s\n", strings.Repeat(" ", gutter), r.paint("=", ansiBlue), r.paint("note:", ansiBold)+" "+note)
+	}
+
+	for _, hint := range d.Hints {
+		fmt.Fprintf(&sb, "%s %s %s\n", strings.Repeat(" ", gutter), r.paint("=", ansiBlue), r.paint("hint:", ansiBold+ansiGreen)+" "+hint)
+	}
+
+	fmt.Fprint(r.w, sb.String())
+}
+
+// Writes the line of source code of a span, underlining the span if its
+// column is known.
+func (r *Renderer) renderSnippet(sb *strings.Builder, span Span, gutter int) {
+	line := strings.TrimSuffix(r.lines[span.Line-1], "\r")
+	padding := strings.Repeat(" ", gutter)
+
+	fmt.Fprintf(sb, "%s %s\n", padding, r.paint("|", ansiBlue))
+	fmt.Fprintf(sb, "%s %s %s\n", r.paint(strconv.Itoa(span.Line), ansiBlue), r.paint("|", ansiBlue), line)
+
+	if span.Column <= 0 {
+		return
+	}
+
+	// The prefix of the line before the span, which keeps tabs so that the
+	// underline is aligned with the span
+	prefix := []rune(line)
+	if len(prefix) > span.Column-1 {
+		prefix = prefix[:span.Column-1]
+	}
+
+	var indent strings.Builder
+	for _, ch := range prefix {
+		if ch == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+
+	// The underline stops at the end of the line for spans over multiple lines
+	width := 1
+	if 0 <= span.Start && span.Start < span.End && span.End <= len(r.source) {
+		text, _, _ := strings.Cut(r.source[span.Start:span.End], "\n")
+		width = max(utf8.RuneCountInString(strings.TrimSuffix(text, "\r")), 1)
+	}
+
+	fmt.Fprintf(sb, "%s %s %s%s\n", padding, r.paint("|", ansiBlue), indent.String(), r.paint(strings.Repeat("^", width), ansiBold+ansiRed))
+}
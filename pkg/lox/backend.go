@@ -0,0 +1,46 @@
+package lox
+
+import (
+	"context"
+	"io"
+
+	"github.com/kevhlee/glox/pkg/ast"
+)
+
+// Backend is a strategy for executing Lox programs.
+//
+// By default, an [Interpreter] walks the AST of a program. Other backends (e.g.
+// a bytecode virtual machine) can be selected with [WithBackend].
+type Backend interface {
+	// Execute executes a program that was parsed and resolved without errors.
+	//
+	// Runtime errors are returned as an [*Error]. A [parser.ErrorList] is
+	// returned if the backend cannot compile the program.
+	Execute(ctx context.Context, config *Config, body []ast.Stmt) error
+}
+
+// Config is the configuration of an [Interpreter] that its [Backend] executes
+// programs with. See the options of [NewInterpreter] for the meaning of each
+// setting.
+type Config struct {
+	Stdout          io.Writer
+	Globals         *Environment
+	StepLimit       int
+	MaxCallDepth    int
+	MaxEnvDepth     int
+	AllocationLimit int
+}
+
+// The default backend, which walks the AST.
+type treeWalker struct{}
+
+// Execute implements the [Backend] interface.
+func (treeWalker) Execute(ctx context.Context, config *Config, body []ast.Stmt) error {
+	ip := interpreter{
+		stdout:       config.Stdout,
+		budget:       NewBudget(ctx, config),
+		maxCallDepth: config.MaxCallDepth,
+		maxEnvDepth:  config.MaxEnvDepth,
+	}
+	return ip.Interpret(config.Globals, body)
+}
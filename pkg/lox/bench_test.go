@@ -0,0 +1,42 @@
+package lox_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/kevhlee/glox/pkg/lox"
+)
+
+// BenchmarkArithmetic measures a loop of arithmetic and comparisons.
+func BenchmarkArithmetic(b *testing.B) {
+	benchmarkRun(b, `
+var sum = 0;
+for (var i = 0; i < 10000; i = i + 1) {
+  sum = sum + i * 2 - i / 4;
+  if (sum > 1000000) sum = sum - 1000000;
+}
+`)
+}
+
+// BenchmarkFib measures recursive calls doing arithmetic.
+func BenchmarkFib(b *testing.B) {
+	benchmarkRun(b, `
+fun fib(n) {
+  if (n < 2) return n;
+  return fib(n - 1) + fib(n - 2);
+}
+fib(18);
+`)
+}
+
+func benchmarkRun(b *testing.B, source string) {
+	in := lox.NewInterpreter(lox.WithStdout(io.Discard))
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		if err := in.Run(source); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
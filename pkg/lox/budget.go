@@ -0,0 +1,69 @@
+package lox
+
+import "context"
+
+// The number of steps between each check for whether a run's context was
+// cancelled.
+const cancelCheckInterval = 1024
+
+// The approximate number of bytes counted for Lox values that are not strings.
+// A function is counted for each function declaration and for each method of a
+// class declaration, and an instance for each call to a class.
+const (
+	FunctionSize = 48
+	InstanceSize = 64
+)
+
+// Budget counts the steps executed and bytes allocated by a single run of a
+// program, so that every [Backend] enforces the limits of its [Config] and the
+// cancellation of its context the same way.
+//
+// The errors returned by a budget have no location, which the backend adds
+// before raising them.
+type Budget struct {
+	ctx    context.Context
+	config *Config
+
+	steps     int
+	allocated int
+}
+
+// NewBudget creates a budget for a run with a context and configuration.
+func NewBudget(ctx context.Context, config *Config) *Budget {
+	return &Budget{ctx: ctx, config: config}
+}
+
+// Step counts an execution step. It returns a [KindBudgetExceeded] error if the
+// step limit was exceeded, or a [KindCancelled] error if the context was
+// cancelled.
+func (b *Budget) Step() *Error {
+	b.steps++
+
+	if b.config.StepLimit > 0 && b.steps > b.config.StepLimit {
+		return &Error{Msg: "Execution step budget exceeded", Kind: KindBudgetExceeded}
+	}
+
+	// Checking the context on the first step stops execution from starting
+	// with an already cancelled context
+	if b.steps%cancelCheckInterval == 1 {
+		select {
+		case <-b.ctx.Done():
+			return &Error{Msg: "Execution cancelled", Kind: KindCancelled}
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Allocate counts an approximate number of bytes allocated for a Lox value. It
+// returns a [KindOutOfMemory] error if the allocation limit was exceeded.
+func (b *Budget) Allocate(size int) *Error {
+	b.allocated += size
+
+	if b.config.AllocationLimit > 0 && b.allocated > b.config.AllocationLimit {
+		return &Error{Msg: "Out of memory", Kind: KindOutOfMemory}
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package lox
+
+import (
+	"fmt"
+
+	"github.com/kevhlee/glox/pkg/ast"
+	"github.com/kevhlee/glox/pkg/token"
+)
+
+// Callable is a Lox object that can be called.
+type Callable interface {
+	Object
+
+	// Arity returns the number of arguments the callable expects.
+	Arity() int
+
+	// Name returns the name of the callable.
+	Name() string
+
+	// Invokes the callable with arguments from a call at the given closing
+	// parenthesis.
+	call(ip *interpreter, paren *token.Token, args []Value) Value
+}
+
+// Function is a user-defined Lox function.
+//
+// A function closes over the environment that was active at the time of its
+// declaration.
+type Function struct {
+	decl          *ast.FunctionStmt
+	closure       *Environment
+	isInitializer bool
+}
+
+// Arity implements the [Callable] interface.
+func (fn *Function) Arity() int {
+	return len(fn.decl.Params)
+}
+
+// Name implements the [Callable] interface.
+func (fn *Function) Name() string {
+	return fn.decl.Name.Lexeme
+}
+
+// String implements the [fmt.Stringer] interface.
+func (fn *Function) String() string {
+	return fmt.Sprintf("<fn %s>", fn.decl.Name.Lexeme)
+}
+
+// TypeName implements the [Object] interface.
+func (fn *Function) TypeName() string {
+	return "function"
+}
+
+func (fn *Function) call(ip *interpreter, paren *token.Token, args []Value) Value {
+	env := newInnerEnvironment(fn.closure)
+	for i, param := range fn.decl.Params {
+		env.Define(param.Lexeme, args[i])
+	}
+
+	value := ip.executeFunction(fn.decl.Body, env, paren)
+
+	// Initializers always return the instance being constructed
+	if fn.isInitializer {
+		return fn.closure.GetAt(0, "this")
+	}
+
+	return value
+}
+
+// Creates a copy of the method whose closure binds 'this' to an instance.
+func (fn *Function) bind(instance *Instance) *Function {
+	env := newInnerEnvironment(fn.closure)
+	env.Define("this", ObjectValue(instance))
+
+	return &Function{decl: fn.decl, closure: env, isInitializer: fn.isInitializer}
+}
+
+// NativeFunc is a Lox function implemented in Go.
+//
+// Native functions may return an error to raise a Lox runtime error. If the
+// error is an [*Error] with a non-zero line, that line is reported. Otherwise,
+// the error is reported at the line of the call.
+type NativeFunc struct {
+	name  string
+	arity int
+	fn    func(args []Value) (Value, error)
+}
+
+// Arity implements the [Callable] interface.
+func (fn *NativeFunc) Arity() int {
+	return fn.arity
+}
+
+// Name implements the [Callable] interface.
+func (fn *NativeFunc) Name() string {
+	return fn.name
+}
+
+// String implements the [fmt.Stringer] interface.
+func (fn *NativeFunc) String() string {
+	return "<native fn>"
+}
+
+// TypeName implements the [Object] interface.
+func (fn *NativeFunc) TypeName() string {
+	return "function"
+}
+
+// Call invokes the Go function implementing the native function.
+//
+// This is useful for backends that call native functions without going through
+// the [Callable] interface. The number of arguments is not checked.
+func (fn *NativeFunc) Call(args []Value) (Value, error) {
+	return fn.fn(args)
+}
+
+func (fn *NativeFunc) call(ip *interpreter, paren *token.Token, args []Value) Value {
+	value, err := fn.fn(args)
+	if err == nil {
+		return value
+	}
+
+	panic(NativeError(err, paren.Line, paren))
+}
+
+// NativeError converts an error returned by a native function into the runtime
+// error it raises, given the line and token (nil if unknown) of the call.
+//
+// An [*Error] keeps its line if it has one. It is copied, since natives may
+// return the same error from different calls.
+func NativeError(err error, line int, tok *token.Token) *Error {
+	e, ok := err.(*Error)
+	if !ok {
+		return &Error{Msg: err.Error(), Line: line, Kind: KindRuntime, Token: tok}
+	}
+
+	copied := *e
+	if copied.Line == 0 {
+		copied.Line = line
+		copied.Token = tok
+	}
+	return &copied
+}
@@ -0,0 +1,111 @@
+package lox
+
+import (
+	"fmt"
+
+	"github.com/kevhlee/glox/pkg/token"
+)
+
+// Class is a Lox class.
+//
+// Calling a class constructs a new instance of it.
+type Class struct {
+	name       string
+	superclass *Class
+	methods    map[string]*Function
+}
+
+// Arity implements the [Callable] interface.
+func (c *Class) Arity() int {
+	if init, ok := c.findMethod("init"); ok {
+		return init.Arity()
+	}
+	return 0
+}
+
+// Name implements the [Callable] interface.
+func (c *Class) Name() string {
+	return c.name
+}
+
+// Superclass returns the superclass of the class, or nil if the class does
+// not inherit from another class.
+func (c *Class) Superclass() *Class {
+	return c.superclass
+}
+
+// String implements the [fmt.Stringer] interface.
+func (c *Class) String() string {
+	return c.name
+}
+
+// TypeName implements the [Object] interface.
+func (c *Class) TypeName() string {
+	return "class"
+}
+
+func (c *Class) call(ip *interpreter, paren *token.Token, args []Value) Value {
+	ip.allocate(InstanceSize, paren)
+	instance := &Instance{class: c, fields: make(map[string]Value)}
+
+	if init, ok := c.findMethod("init"); ok {
+		init.bind(instance).call(ip, paren, args)
+	}
+
+	return ObjectValue(instance)
+}
+
+func (c *Class) findMethod(name string) (*Function, bool) {
+	if method, ok := c.methods[name]; ok {
+		return method, true
+	}
+
+	if c.superclass != nil {
+		return c.superclass.findMethod(name)
+	}
+
+	return nil, false
+}
+
+// Instance is an instance of a Lox class.
+type Instance struct {
+	class  *Class
+	fields map[string]Value
+}
+
+// Class returns the class of the instance.
+func (in *Instance) Class() *Class {
+	return in.class
+}
+
+// Get retrieves the value of a property of the instance if it exists.
+//
+// Fields shadow methods of the same name. Methods are returned bound to the
+// instance. This function returns a boolean value to indicate if the property
+// exists.
+func (in *Instance) Get(name string) (Value, bool) {
+	if value, ok := in.fields[name]; ok {
+		return value, true
+	}
+
+	if method, ok := in.class.findMethod(name); ok {
+		return ObjectValue(method.bind(in)), true
+	}
+
+	return Value{}, false
+}
+
+// Set creates or replaces a field of the instance.
+func (in *Instance) Set(name string, value Value) {
+	in.fields[name] = value
+}
+
+// String implements the [fmt.Stringer] interface.
+func (in *Instance) String() string {
+	return fmt.Sprintf("%s instance", in.class.name)
+}
+
+// TypeName implements the [Object] interface.
+func (in *Instance) TypeName() string {
+	return "instance"
+}
@@ -0,0 +1,79 @@
+package lox
+
+import (
+	"github.com/kevhlee/glox/pkg/parser"
+	"github.com/kevhlee/glox/pkg/token"
+)
+
+// Diagnostic is a machine-readable description of an error returned by
+// [Interpreter.Run].
+type Diagnostic struct {
+	// The severity of the diagnostic, which is always "error".
+	Severity string `json:"severity"`
+
+	// The error message.
+	Message string `json:"message"`
+
+	// The name of the file containing the source code, if known.
+	File string `json:"file,omitempty"`
+
+	// The line and column (both starting from 1) of the error. A zero column
+	// means it is unknown, and a zero line means the error is not in the
+	// source code (e.g. a compiled file is corrupted).
+	Line   int `json:"line"`
+	Column int `json:"column"`
+
+	// The lexeme of the token where the error occurred, if known.
+	Lexeme string `json:"lexeme"`
+
+	// The phase where the error occurred, which is either "compile" or
+	// "runtime".
+	Phase string `json:"phase"`
+}
+
+// Diagnostics converts an error returned by [Interpreter.Run] into a list of
+// diagnostics for the given file name.
+//
+// The list is empty if the error is nil or was not raised by the interpreter.
+func Diagnostics(filename string, err error) []Diagnostic {
+	var diagnostics []Diagnostic
+
+	switch err := err.(type) {
+	case parser.ErrorList:
+		for _, err := range err {
+			d := Diagnostic{
+				Severity: "error",
+				Message:  err.Msg,
+				File:     filename,
+				Line:     err.Token.Line,
+				Column:   err.Token.Column,
+				Phase:    "compile",
+			}
+
+			// The lexeme of an error token is its message
+			if err.Token.Type != token.ERROR {
+				d.Lexeme = err.Token.Lexeme
+			}
+
+			diagnostics = append(diagnostics, d)
+		}
+
+	case *Error:
+		d := Diagnostic{
+			Severity: "error",
+			Message:  err.Msg,
+			File:     filename,
+			Line:     err.Line,
+			Phase:    "runtime",
+		}
+
+		if err.Token != nil && err.Token.Line == err.Line {
+			d.Column = err.Token.Column
+			d.Lexeme = err.Token.Lexeme
+		}
+
+		diagnostics = append(diagnostics, d)
+	}
+
+	return diagnostics
+}
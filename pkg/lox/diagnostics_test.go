@@ -0,0 +1,50 @@
+package lox_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/kevhlee/glox/pkg/lox"
+)
+
+// TestDiagnostics checks that compile and runtime errors are converted into
+// structured diagnostics.
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected []lox.Diagnostic
+	}{
+		{
+			source: "print 1\n@",
+			expected: []lox.Diagnostic{
+				{Severity: "error", Message: "Unexpected character", File: "test.lox", Line: 2, Column: 1, Phase: "compile"},
+				{Severity: "error", Message: "Expect ';' after value", File: "test.lox", Line: 2, Column: 2, Phase: "compile"},
+			},
+		},
+		{
+			source: "var a = 1;\nprint a + nil;",
+			expected: []lox.Diagnostic{
+				{Severity: "error", Message: "Operands must be two numbers or two strings", File: "test.lox", Line: 2, Column: 9, Lexeme: "+", Phase: "runtime"},
+			},
+		},
+		{
+			source: "print 1;",
+		},
+	}
+
+	for _, test := range tests {
+		in := lox.NewInterpreter(lox.WithStdout(io.Discard), lox.WithStderr(io.Discard))
+
+		actual := lox.Diagnostics("test.lox", in.Run(test.source))
+		if len(actual) != len(test.expected) {
+			t.Errorf("Expected %d diagnostics for '%s', got %d instead", len(test.expected), test.source, len(actual))
+			continue
+		}
+
+		for i := range test.expected {
+			if actual[i] != test.expected[i] {
+				t.Errorf("Expected diagnostic '%+v', got '%+v' instead", test.expected[i], actual[i])
+			}
+		}
+	}
+}
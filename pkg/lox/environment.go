@@ -1,5 +1,10 @@
 package lox
 
+import (
+	"maps"
+	"slices"
+)
+
 // Environment is a data structure to store named bindings for a given Lox
 // scope.
 //
@@ -7,26 +12,38 @@ package lox
 // internally managed reference to the one enclosing it.
 type Environment struct {
 	outer  *Environment
-	values map[string]any
+	values map[string]Value
+
+	// The number of environments enclosing this one
+	depth int
 }
 
 // NewEnvironment creates a new environment.
 func NewEnvironment() *Environment {
 	return &Environment{
 		outer:  nil,
-		values: make(map[string]any),
+		values: make(map[string]Value),
 	}
 }
 
+// NewGlobalEnvironment creates a new environment with Lox's standard native
+// functions defined.
+func NewGlobalEnvironment() *Environment {
+	env := NewEnvironment()
+	defineNatives(env)
+	return env
+}
+
 func newInnerEnvironment(outer *Environment) *Environment {
 	return &Environment{
 		outer:  outer,
-		values: make(map[string]any),
+		values: make(map[string]Value),
+		depth:  outer.depth + 1,
 	}
 }
 
 // Define creates a named binding.
-func (env *Environment) Define(name string, value any) {
+func (env *Environment) Define(name string, value Value) {
 	env.values[name] = value
 }
 
@@ -34,7 +51,7 @@ func (env *Environment) Define(name string, value any) {
 //
 // This function will search through outer environments for the named binding
 // and return a boolean value to indicate if the named binding exists.
-func (env *Environment) Assign(name string, value any) bool {
+func (env *Environment) Assign(name string, value Value) bool {
 	if _, ok := env.values[name]; ok {
 		env.values[name] = value
 		return true
@@ -47,11 +64,28 @@ func (env *Environment) Assign(name string, value any) bool {
 	return false
 }
 
+// AssignAt replaces the value of a named binding in the environment a given
+// number of scopes outwards.
+//
+// Unlike [Environment.Assign], this function assumes the distance was computed
+// by the resolver and does not search for the named binding.
+func (env *Environment) AssignAt(distance int, name string, value Value) {
+	env.ancestor(distance).values[name] = value
+}
+
+// DefineNative creates a named binding to a native function.
+//
+// The native function is called with exactly arity arguments. See
+// [NativeFunc] for how returned errors are reported.
+func (env *Environment) DefineNative(name string, arity int, fn func(args []Value) (Value, error)) {
+	env.Define(name, ObjectValue(&NativeFunc{name: name, arity: arity, fn: fn}))
+}
+
 // Get retrieves the value of a named binding if it exists.
 //
 // This function will search through outer environments for the named binding
 // and return a boolean value to indicate if the named binding exists.
-func (env *Environment) Get(name string) (any, bool) {
+func (env *Environment) Get(name string) (Value, bool) {
 	if value, ok := env.values[name]; ok {
 		return value, ok
 	}
@@ -60,5 +94,30 @@ func (env *Environment) Get(name string) (any, bool) {
 		return env.outer.Get(name)
 	}
 
-	return nil, false
+	return Value{}, false
+}
+
+// GetAt retrieves the value of a named binding in the environment a given
+// number of scopes outwards.
+//
+// Unlike [Environment.Get], this function assumes the distance was computed by
+// the resolver and does not search for the named binding.
+func (env *Environment) GetAt(distance int, name string) Value {
+	return env.ancestor(distance).values[name]
+}
+
+// Names returns the names of the bindings defined directly in the environment
+// in alphabetical order.
+//
+// Unlike [Environment.Get], this function does not include the bindings of
+// outer environments.
+func (env *Environment) Names() []string {
+	return slices.Sorted(maps.Keys(env.values))
+}
+
+func (env *Environment) ancestor(distance int) *Environment {
+	for range distance {
+		env = env.outer
+	}
+	return env
 }
@@ -1,12 +1,64 @@
 package lox
 
+import "github.com/kevhlee/glox/pkg/token"
+
+// ErrorKind is the kind of a Lox runtime error.
+type ErrorKind int
+
+const (
+	// KindRuntime is the kind of an error raised by the executing Lox program.
+	KindRuntime ErrorKind = iota
+
+	// KindCancelled is the kind of an error raised when execution was stopped
+	// because its context was cancelled.
+	KindCancelled
+
+	// KindBudgetExceeded is the kind of an error raised when execution was
+	// stopped because it ran more steps than its budget allows.
+	KindBudgetExceeded
+
+	// KindStackOverflow is the kind of an error raised when execution was
+	// stopped because calls or scopes were nested deeper than allowed.
+	KindStackOverflow
+
+	// KindOutOfMemory is the kind of an error raised when execution was
+	// stopped because it allocated more bytes in total than allowed.
+	KindOutOfMemory
+)
+
 // Error is a Lox runtime error.
 type Error struct {
 	Msg  string
 	Line int
+	Kind ErrorKind
+
+	// The token where the error occurred, if known.
+	Token *token.Token
+
+	// The stack trace of the calls being executed when the error occurred,
+	// starting from the outermost call.
+	Trace []Frame
+}
+
+// Frame is a frame of a Lox stack trace.
+type Frame struct {
+	// The name of the called function, or "script" for the top-level code.
+	Function string
+
+	// The line being executed in the frame.
+	Line int
 }
 
 // Error implements the [error] interface.
 func (err *Error) Error() string {
 	return err.Msg
 }
+
+// Creates an error at a given token, which may be nil if it is unknown.
+func newError(kind ErrorKind, tok *token.Token, msg string) *Error {
+	err := &Error{Msg: msg, Kind: kind, Token: tok}
+	if tok != nil {
+		err.Line = tok.Line
+	}
+	return err
+}
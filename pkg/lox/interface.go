@@ -2,10 +2,15 @@
 package lox
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 
+	"github.com/kevhlee/glox/pkg/ast"
+	"github.com/kevhlee/glox/pkg/diag"
 	"github.com/kevhlee/glox/pkg/parser"
+	"github.com/kevhlee/glox/pkg/resolver"
 	"github.com/kevhlee/glox/pkg/token"
 )
 
@@ -23,44 +28,311 @@ const (
 	ExitRuntimeErr = 70
 )
 
+// ExitStatus returns the exit status code corresponding to an error returned
+// by [Interpreter.Run].
+func ExitStatus(err error) int {
+	switch err.(type) {
+	case nil:
+		return ExitOK
+	case parser.ErrorList:
+		return ExitCompileErr
+	default:
+		return ExitRuntimeErr
+	}
+}
+
 // RunSource executes Lox source code.
 //
 // The function returns a exit status code that can be passed to [os.Exit] as an
 // argument.
 func RunSource(globals *Environment, source string) int {
+	return ExitStatus(NewInterpreter(WithGlobals(globals)).Run(source))
+}
+
+// Interpreter is an embeddable Lox interpreter.
+//
+// Global bindings persist across calls to [Interpreter.Run], so an interpreter
+// can execute a program incrementally (e.g. in a REPL).
+type Interpreter struct {
+	config  Config
+	stderr  io.Writer
+	backend Backend
+}
+
+const (
+	// DefaultMaxCallDepth is the default maximum depth of nested calls.
+	DefaultMaxCallDepth = 10000
+
+	// DefaultMaxEnvDepth is the default maximum depth of nested scopes.
+	DefaultMaxEnvDepth = 10000
+)
+
+// Option configures an [Interpreter].
+type Option func(*Interpreter)
+
+// WithStdout sets the writer that print statements write to. By default, this
+// is [os.Stdout].
+func WithStdout(w io.Writer) Option {
+	return func(in *Interpreter) {
+		in.config.Stdout = w
+	}
+}
+
+// WithStderr sets the writer that errors are reported to. By default, this is
+// [os.Stderr].
+func WithStderr(w io.Writer) Option {
+	return func(in *Interpreter) {
+		in.stderr = w
+	}
+}
+
+// WithGlobals sets the environment used for global bindings. By default, this
+// is a new environment created by [NewGlobalEnvironment].
+func WithGlobals(globals *Environment) Option {
+	return func(in *Interpreter) {
+		in.config.Globals = globals
+	}
+}
+
+// WithStepLimit sets the maximum number of steps a single call to
+// [Interpreter.Run] may execute before it is aborted with a
+// [KindBudgetExceeded] error. A step is taken for each statement and expression
+// evaluated. By default, or if the limit is not positive, there is no limit.
+func WithStepLimit(limit int) Option {
+	return func(in *Interpreter) {
+		in.config.StepLimit = limit
+	}
+}
+
+// WithMaxCallDepth sets the maximum depth of nested calls before execution is
+// aborted with a [KindStackOverflow] error. By default, this is
+// [DefaultMaxCallDepth]. If the limit is not positive, there is no limit.
+//
+// Without a limit, deep recursion in a Lox program can overflow the Go stack
+// and crash the process.
+func WithMaxCallDepth(limit int) Option {
+	return func(in *Interpreter) {
+		in.config.MaxCallDepth = limit
+	}
+}
+
+// WithMaxEnvDepth sets the maximum depth of nested scopes before execution is
+// aborted with a [KindStackOverflow] error. By default, this is
+// [DefaultMaxEnvDepth]. If the limit is not positive, there is no limit.
+func WithMaxEnvDepth(limit int) Option {
+	return func(in *Interpreter) {
+		in.config.MaxEnvDepth = limit
+	}
+}
+
+// WithAllocationLimit sets the approximate number of bytes a single call to
+// [Interpreter.Run] may allocate for strings, functions and instances before
+// it is aborted with a [KindOutOfMemory] error. By default, or if the limit is
+// not positive, there is no limit.
+//
+// This is a budget for the total allocated over the whole run, not a limit on
+// live memory: bytes are counted when they are allocated and never given back
+// when the garbage collector reclaims them.
+func WithAllocationLimit(limit int) Option {
+	return func(in *Interpreter) {
+		in.config.AllocationLimit = limit
+	}
+}
+
+// WithBackend sets the backend that executes programs. By default, programs
+// are executed by walking their AST.
+func WithBackend(backend Backend) Option {
+	return func(in *Interpreter) {
+		in.backend = backend
+	}
+}
+
+// NewInterpreter creates a new interpreter.
+func NewInterpreter(opts ...Option) *Interpreter {
+	in := &Interpreter{
+		config: Config{
+			Stdout:       os.Stdout,
+			MaxCallDepth: DefaultMaxCallDepth,
+			MaxEnvDepth:  DefaultMaxEnvDepth,
+		},
+		stderr:  os.Stderr,
+		backend: treeWalker{},
+	}
+
+	for _, opt := range opts {
+		opt(in)
+	}
+
+	if in.config.Globals == nil {
+		in.config.Globals = NewGlobalEnvironment()
+	}
+
+	return in
+}
+
+// Globals returns the environment used for global bindings.
+func (in *Interpreter) Globals() *Environment {
+	return in.config.Globals
+}
+
+// Config returns the configuration the interpreter executes programs with.
+//
+// This is useful for executing programs that were compiled ahead of time with
+// the same settings as the interpreter.
+func (in *Interpreter) Config() *Config {
+	return &in.config
+}
+
+// Run executes Lox source code.
+//
+// Errors are reported to the interpreter's stderr writer and returned. A
+// [parser.ErrorList] is returned if the source code has compile errors, and an
+// [*Error] is returned if execution failed at runtime.
+func (in *Interpreter) Run(source string) error {
+	return in.RunContext(context.Background(), source)
+}
+
+// RunContext executes Lox source code like [Interpreter.Run], but aborts
+// execution with a [KindCancelled] error if the context is cancelled.
+func (in *Interpreter) RunContext(ctx context.Context, source string) error {
+	parsed, err := in.Check(source)
+	if err != nil {
+		return err
+	}
+	return in.execute(ctx, source, parsed)
+}
+
+// RunInteractive executes input from an interactive session like
+// [Interpreter.Run].
+//
+// The input may also be a single expression without a trailing semicolon, in
+// which case its value is printed like a print statement.
+func (in *Interpreter) RunInteractive(source string) error {
+	parsed, expr, err := parser.ParseInteractive(source)
+	if expr != nil {
+		parsed = []ast.Stmt{&ast.PrintStmt{Value: expr}}
+	}
+
+	if parsed, err = in.resolve(source, parsed, err); err != nil {
+		return err
+	}
+	return in.execute(context.Background(), source, parsed)
+}
+
+// Check parses and resolves Lox source code without executing it.
+//
+// Errors are reported to the interpreter's stderr writer and returned as a
+// [parser.ErrorList].
+func (in *Interpreter) Check(source string) ([]ast.Stmt, error) {
 	parsed, err := parser.ParseSource(source)
+	return in.resolve(source, parsed, err)
+}
+
+// ReportError reports an error to the interpreter's stderr writer like
+// [Interpreter.Run] does, given the source code it was raised for.
+//
+// This is useful for reporting errors raised outside of the interpreter (e.g.
+// by a compiler), since only a [parser.ErrorList] or an [*Error] is reported.
+func (in *Interpreter) ReportError(source string, err error) {
+	switch err := err.(type) {
+	case parser.ErrorList:
+		in.reportCompileErrors(source, err)
+	case *Error:
+		reportRuntimeError(in.stderr, diag.NewRenderer(in.stderr, source), err)
+	}
+}
+
+// Resolves the result of parsing source code, reporting any errors.
+func (in *Interpreter) resolve(source string, parsed []ast.Stmt, err error) ([]ast.Stmt, error) {
+	if err == nil {
+		err = resolver.Resolve(parsed)
+	}
 
 	if err != nil {
-		for _, err := range err.(parser.ErrorList) {
-			switch err.Token.Type {
-			case token.EOF:
-				reportCompileError(err.Token.Line, " at end", err.Error())
-			case token.ERROR:
-				reportCompileError(err.Token.Line, "", err.Error())
-			default:
-				reportCompileError(err.Token.Line, fmt.Sprintf(" at '%s'", err.Token.Lexeme), err.Error())
-			}
-		}
+		in.reportCompileErrors(source, err.(parser.ErrorList))
+		return nil, err
+	}
 
-		return ExitCompileErr
+	return parsed, nil
+}
+
+// Executes a resolved AST, reporting any errors.
+func (in *Interpreter) execute(ctx context.Context, source string, parsed []ast.Stmt) error {
+	err := in.backend.Execute(ctx, &in.config, parsed)
+	in.ReportError(source, err)
+	return err
+}
+
+func (in *Interpreter) reportCompileErrors(source string, errs parser.ErrorList) {
+	r := diag.NewRenderer(in.stderr, source)
+
+	for _, err := range errs {
+		switch err.Token.Type {
+		case token.EOF:
+			reportCompileError(r, err.Token, " at end", err.Error())
+		case token.ERROR:
+			reportCompileError(r, err.Token, "", err.Error())
+		default:
+			reportCompileError(r, err.Token, fmt.Sprintf(" at '%s'", err.Token.Lexeme), err.Error())
+		}
 	}
+}
+
+func reportCompileError(r *diag.Renderer, tok *token.Token, where, msg string) {
+	r.Render(&diag.Diagnostic{
+		Message: fmt.Sprintf("[line %d] Error%s: %s.", tok.Line, where, msg),
+		Span:    tokenSpan(tok),
+	})
+}
 
-	var ip interpreter
+func reportRuntimeError(w io.Writer, r *diag.Renderer, err *Error) {
+	// Only errors raised inside a call have an interesting stack trace
+	if len(err.Trace) > 1 {
+		fmt.Fprintln(w, "Traceback (most recent call last):")
 
-	if err := ip.Interpret(globals, parsed); err != nil {
-		if err, ok := err.(*Error); ok {
-			reportRuntimeError(err.Line, err.Error())
+		// Like Python, identical frames from deep recursion are collapsed
+		// after a few repeats
+		repeats := 0
+		for i, frame := range err.Trace {
+			if i > 0 && frame == err.Trace[i-1] {
+				repeats++
+			} else {
+				reportRepeatedFrames(w, repeats)
+				repeats = 0
+			}
+
+			switch {
+			case repeats >= maxRepeatedFrames:
+				continue
+			case frame.Function == "script":
+				fmt.Fprintf(w, "  line %d, in %s\n", frame.Line, frame.Function)
+			default:
+				fmt.Fprintf(w, "  line %d, in %s()\n", frame.Line, frame.Function)
+			}
 		}
-		return ExitRuntimeErr
+		reportRepeatedFrames(w, repeats)
+	}
+
+	span := diag.Span{Line: err.Line}
+	if err.Token != nil && err.Token.Line == err.Line {
+		span = tokenSpan(err.Token)
 	}
 
-	return ExitOK
+	r.Render(&diag.Diagnostic{
+		Message: fmt.Sprintf("%s.\n[line %d]", err.Msg, err.Line),
+		Span:    span,
+	})
 }
 
-func reportCompileError(line int, where, msg string) {
-	fmt.Fprintf(os.Stderr, "[line %d] Error%s: %s.\n", line, where, msg)
+// The number of identical frames reported in a row before they are collapsed.
+const maxRepeatedFrames = 3
+
+func reportRepeatedFrames(w io.Writer, repeats int) {
+	if n := repeats - maxRepeatedFrames + 1; n > 0 {
+		fmt.Fprintf(w, "  [Previous line repeated %d more times]\n", n)
+	}
 }
 
-func reportRuntimeError(line int, msg string) {
-	fmt.Fprintf(os.Stderr, "%s.\n[line %d]\n", msg, line)
+func tokenSpan(tok *token.Token) diag.Span {
+	return diag.Span{Line: tok.Line, Column: tok.Column, Start: tok.Start, End: tok.End}
 }
@@ -2,6 +2,7 @@ package lox
 
 import (
 	"fmt"
+	"io"
 	"strconv"
 
 	"github.com/kevhlee/glox/internal/stack"
@@ -11,9 +12,30 @@ import (
 
 // Contains the internal state and logic of the interpreter.
 type interpreter struct {
-	env      *Environment
-	globals  *Environment
-	operands stack.Stack[any]
+	stdout io.Writer
+
+	// Counts steps and allocations against the limits of the run
+	budget *Budget
+
+	// The maximum depth of calls and environments, or 0 if unlimited
+	maxCallDepth int
+	maxEnvDepth  int
+
+	// The calls currently being executed, used to build stack traces
+	calls stack.Stack[call]
+
+	env     *Environment
+	globals *Environment
+
+	// Set by a return statement to unwind execution back to the function call
+	returning   bool
+	returnValue Value
+}
+
+// A call currently being executed by the interpreter.
+type call struct {
+	callee Callable
+	paren  *token.Token
 }
 
 func (ip *interpreter) Interpret(globals *Environment, body []ast.Stmt) (err error) {
@@ -26,6 +48,7 @@ func (ip *interpreter) Interpret(globals *Environment, body []ast.Stmt) (err err
 
 		if r := recover(); r != nil {
 			if r, ok := r.(*Error); ok {
+				r.Trace = ip.trace(r.Line)
 				err = r
 			} else {
 				panic(r)
@@ -34,61 +57,180 @@ func (ip *interpreter) Interpret(globals *Environment, body []ast.Stmt) (err err
 	}()
 
 	for _, stmt := range body {
-		ast.Walk(ip, stmt)
+		if ip.execute(stmt); ip.returning {
+			break
+		}
 	}
 	return
 }
 
-// Visit implements the [ast.Visitor] interface.
-func (ip *interpreter) Visit(node ast.Node) bool {
-	switch node := node.(type) {
-	// Stmt
+// Executes a statement.
+func (ip *interpreter) execute(stmt ast.Stmt) {
+	ip.step(stmt)
+
+	switch stmt := stmt.(type) {
 	case *ast.BlockStmt:
-		ip.handleBlockStmt(node)
+		ip.handleBlockStmt(stmt)
+	case *ast.ClassStmt:
+		ip.handleClassStmt(stmt)
 	case *ast.ExpressionStmt:
-		ip.handleExprStmt(node)
+		ip.handleExprStmt(stmt)
+	case *ast.FunctionStmt:
+		ip.handleFunctionStmt(stmt)
+	case *ast.IfStmt:
+		ip.handleIfStmt(stmt)
 	case *ast.PrintStmt:
-		ip.handlePrintStmt(node)
+		ip.handlePrintStmt(stmt)
+	case *ast.ReturnStmt:
+		ip.handleReturnStmt(stmt)
 	case *ast.VarStmt:
-		ip.handleVarStmt(node)
+		ip.handleVarStmt(stmt)
+	case *ast.WhileStmt:
+		ip.handleWhileStmt(stmt)
+	default:
+		panic(fmt.Errorf("Unexpected statement type %T", stmt))
+	}
+}
 
-	// Expr
+// Evaluates an expression to its value.
+func (ip *interpreter) evaluate(expr ast.Expr) Value {
+	ip.step(expr)
 
+	switch expr := expr.(type) {
 	case *ast.AssignExpr:
-		ip.handleAssignExpr(node)
+		return ip.handleAssignExpr(expr)
 	case *ast.BinaryExpr:
-		ip.handleBinaryExpr(node)
+		return ip.handleBinaryExpr(expr)
+	case *ast.CallExpr:
+		return ip.handleCallExpr(expr)
+	case *ast.GetExpr:
+		return ip.handleGetExpr(expr)
 	case *ast.GroupingExpr:
-		ip.handleGroupingExpr(node)
+		return ip.evaluate(expr.Group)
 	case *ast.LiteralExpr:
-		ip.handleLiteralExpr(node)
+		return ip.handleLiteralExpr(expr)
+	case *ast.LogicalExpr:
+		return ip.handleLogicalExpr(expr)
+	case *ast.SetExpr:
+		return ip.handleSetExpr(expr)
+	case *ast.SuperExpr:
+		return ip.handleSuperExpr(expr)
+	case *ast.ThisExpr:
+		return ip.handleThisExpr(expr)
 	case *ast.UnaryExpr:
-		ip.handleUnaryExpr(node)
+		return ip.handleUnaryExpr(expr)
 	case *ast.VariableExpr:
-		ip.handleVariableExpr(node)
+		return ip.handleVariableExpr(expr)
+	default:
+		panic(fmt.Errorf("Unexpected expression type %T", expr))
 	}
+}
 
-	return false
+// Counts an execution step, aborting execution if the step budget was exceeded
+// or the context was cancelled.
+func (ip *interpreter) step(node ast.Node) {
+	if err := ip.budget.Step(); err != nil {
+		panic(newError(err.Kind, nodeToken(node), err.Msg))
+	}
 }
 
-func (ip *interpreter) execute(stmt ast.Stmt) {
-	ast.Walk(ip, stmt)
+// Builds a stack trace of the calls being executed, given the line being
+// executed in the innermost call.
+func (ip *interpreter) trace(line int) []Frame {
+	frames := make([]Frame, ip.calls.Len()+1)
+	frames[0].Function = "script"
+
+	for i, c := range ip.calls.IterBot() {
+		frames[i].Line = c.paren.Line
+		frames[i+1].Function = c.callee.Name()
+	}
+	frames[len(frames)-1].Line = line
+
+	return frames
 }
 
-func (ip *interpreter) evaluate(expr ast.Expr) any {
-	ast.Walk(ip, expr)
+// Counts an approximate number of bytes allocated for a Lox value, aborting
+// execution if the allocation limit was exceeded.
+func (ip *interpreter) allocate(size int, tok *token.Token) {
+	if err := ip.budget.Allocate(size); err != nil {
+		panic(newError(err.Kind, tok, err.Msg))
+	}
+}
 
-	if operand, ok := ip.operands.Pop(); ok {
-		return operand
+// Executes the body of a block or function in an environment, given the token
+// that the block or call starts at.
+func (ip *interpreter) executeBlock(body []ast.Stmt, env *Environment, tok *token.Token) {
+	if ip.maxEnvDepth > 0 && env.depth > ip.maxEnvDepth {
+		panic(newError(KindStackOverflow, tok, "Stack overflow"))
 	}
-	return nil
+
+	enclosing := ip.env
+
+	defer func() {
+		ip.env = enclosing
+	}()
+
+	ip.env = env
+
+	for _, b := range body {
+		if ip.execute(b); ip.returning {
+			return
+		}
+	}
+}
+
+func (ip *interpreter) executeFunction(body []ast.Stmt, env *Environment, tok *token.Token) Value {
+	ip.executeBlock(body, env, tok)
+
+	value := ip.returnValue
+	ip.returning = false
+	ip.returnValue = Value{}
+	return value
 }
 
-func isTruthy(value any) bool {
-	if b, ok := value.(bool); ok {
-		return b
+// Returns the first token found in a node, or nil if it has none.
+func nodeToken(node ast.Node) *token.Token {
+	switch node := node.(type) {
+	case *ast.BlockStmt:
+		return node.Brace
+	case *ast.ClassStmt:
+		return node.Name
+	case *ast.FunctionStmt:
+		return node.Name
+	case *ast.ReturnStmt:
+		return node.Keyword
+	case *ast.VarStmt:
+		return node.Name
+	case *ast.AssignExpr:
+		return node.Name
+	case *ast.BinaryExpr:
+		return node.Operator
+	case *ast.CallExpr:
+		return node.Paren
+	case *ast.GetExpr:
+		return node.Name
+	case *ast.LiteralExpr:
+		return node.Value
+	case *ast.LogicalExpr:
+		return node.Operator
+	case *ast.SetExpr:
+		return node.Name
+	case *ast.SuperExpr:
+		return node.Keyword
+	case *ast.ThisExpr:
+		return node.Keyword
+	case *ast.UnaryExpr:
+		return node.Operator
+	case *ast.VariableExpr:
+		return node.Name
 	}
-	return value != nil
+
+	for _, child := range ast.Children(node) {
+		if tok := nodeToken(child); tok != nil {
+			return tok
+		}
+	}
+	return nil
 }
 
 //
@@ -96,160 +238,283 @@ func isTruthy(value any) bool {
 //
 
 func (ip *interpreter) handleBlockStmt(stmt *ast.BlockStmt) {
+	ip.executeBlock(stmt.Body, newInnerEnvironment(ip.env), stmt.Brace)
+}
+
+func (ip *interpreter) handleClassStmt(stmt *ast.ClassStmt) {
+	var superclass *Class
+	if stmt.Superclass != nil {
+		var ok bool
+		if superclass, ok = ip.evaluate(stmt.Superclass).AsObject().(*Class); !ok {
+			panic(newError(KindRuntime, stmt.Superclass.Name, "Superclass must be a class"))
+		}
+	}
+
+	ip.env.Define(stmt.Name.Lexeme, Value{})
+
 	enclosing := ip.env
 
 	defer func() {
 		ip.env = enclosing
 	}()
 
-	ip.env = newInnerEnvironment(ip.env)
+	if superclass != nil {
+		ip.env = newInnerEnvironment(ip.env)
+		ip.env.Define("super", ObjectValue(superclass))
+	}
 
-	for _, b := range stmt.Body {
-		ip.execute(b)
+	methods := make(map[string]*Function, len(stmt.Methods))
+	for _, method := range stmt.Methods {
+		ip.allocate(FunctionSize, method.Name)
+		methods[method.Name.Lexeme] = &Function{
+			decl:          method,
+			closure:       ip.env,
+			isInitializer: method.Name.Lexeme == "init",
+		}
 	}
+
+	enclosing.Define(stmt.Name.Lexeme, ObjectValue(&Class{name: stmt.Name.Lexeme, superclass: superclass, methods: methods}))
 }
 
 func (ip *interpreter) handleExprStmt(stmt *ast.ExpressionStmt) {
 	ip.evaluate(stmt.Expression)
 }
 
+func (ip *interpreter) handleFunctionStmt(stmt *ast.FunctionStmt) {
+	ip.allocate(FunctionSize, stmt.Name)
+	ip.env.Define(stmt.Name.Lexeme, ObjectValue(&Function{decl: stmt, closure: ip.env}))
+}
+
+func (ip *interpreter) handleIfStmt(stmt *ast.IfStmt) {
+	if ip.evaluate(stmt.Condition).Truthy() {
+		ip.execute(stmt.Then)
+	} else if stmt.Else != nil {
+		ip.execute(stmt.Else)
+	}
+}
+
 func (ip *interpreter) handlePrintStmt(stmt *ast.PrintStmt) {
-	if value := ip.evaluate(stmt.Value); value != nil {
-		fmt.Printf("%v\n", value)
-	} else {
-		fmt.Println("nil")
+	fmt.Fprintln(ip.stdout, ip.evaluate(stmt.Value).String())
+}
+
+func (ip *interpreter) handleReturnStmt(stmt *ast.ReturnStmt) {
+	var value Value
+	if stmt.Value != nil {
+		value = ip.evaluate(stmt.Value)
 	}
+
+	ip.returning = true
+	ip.returnValue = value
 }
 
 func (ip *interpreter) handleVarStmt(stmt *ast.VarStmt) {
-	var value any
+	var value Value
 	if stmt.Value != nil {
 		value = ip.evaluate(stmt.Value)
 	}
 	ip.env.Define(stmt.Name.Lexeme, value)
 }
 
+func (ip *interpreter) handleWhileStmt(stmt *ast.WhileStmt) {
+	for ip.evaluate(stmt.Condition).Truthy() {
+		if ip.execute(stmt.Body); ip.returning {
+			return
+		}
+	}
+}
+
 //
 // Expr
 //
 
-func (ip *interpreter) handleAssignExpr(expr *ast.AssignExpr) {
+func (ip *interpreter) handleAssignExpr(expr *ast.AssignExpr) Value {
 	value := ip.evaluate(expr.Value)
 
-	if !ip.env.Assign(expr.Name.Lexeme, value) {
-		panic(&Error{
-			Msg:  fmt.Sprintf("Undefined variable '%s'", expr.Name.Lexeme),
-			Line: expr.Name.Line,
-		})
+	if expr.Depth >= 0 {
+		ip.env.AssignAt(expr.Depth, expr.Name.Lexeme, value)
+	} else if !ip.globals.Assign(expr.Name.Lexeme, value) {
+		panic(newError(KindRuntime, expr.Name, fmt.Sprintf("Undefined variable '%s'", expr.Name.Lexeme)))
 	}
 
-	ip.operands.Push(value)
+	return value
 }
 
-func (ip *interpreter) handleBinaryExpr(expr *ast.BinaryExpr) {
+func (ip *interpreter) handleBinaryExpr(expr *ast.BinaryExpr) Value {
 	l, r := ip.evaluate(expr.Left), ip.evaluate(expr.Right)
 
 	switch expr.Operator.Type {
 	case token.PLUS:
-		if lhs, ok := l.(float64); ok {
-			if rhs, ok := r.(float64); ok {
-				ip.operands.Push(lhs + rhs)
-				return
-			}
+		if l.typ == TypeNumber && r.typ == TypeNumber {
+			return NumberValue(l.num + r.num)
 		}
-		if lhs, ok := l.(string); ok {
-			if rhs, ok := r.(string); ok {
-				ip.operands.Push(lhs + rhs)
-				return
-			}
+		if l.typ == TypeString && r.typ == TypeString {
+			lhs, rhs := l.AsString(), r.AsString()
+			ip.allocate(len(lhs)+len(rhs), expr.Operator)
+			return StringValue(lhs + rhs)
 		}
-		panic(&Error{"Operands must be two numbers or two strings", expr.Operator.Line})
+		panic(newError(KindRuntime, expr.Operator, "Operands must be two numbers or two strings"))
 
 	case token.BANG_EQUAL:
-		ip.operands.Push(l != r)
-		return
+		return BoolValue(!l.Equal(r))
 
 	case token.EQUAL_EQUAL:
-		ip.operands.Push(l == r)
-		return
+		return BoolValue(l.Equal(r))
 	}
 
-	lhs, lok := l.(float64)
-	rhs, rok := r.(float64)
-
-	if !(lok && rok) {
-		panic(&Error{"Operands must be numbers", expr.Operator.Line})
+	if l.typ != TypeNumber || r.typ != TypeNumber {
+		panic(newError(KindRuntime, expr.Operator, "Operands must be numbers"))
 	}
 
 	switch expr.Operator.Type {
 	case token.MINUS:
-		ip.operands.Push(lhs - rhs)
+		return NumberValue(l.num - r.num)
 	case token.STAR:
-		ip.operands.Push(lhs * rhs)
+		return NumberValue(l.num * r.num)
 	case token.SLASH:
-		ip.operands.Push(lhs / rhs)
+		return NumberValue(l.num / r.num)
 	case token.GREATER:
-		ip.operands.Push(lhs > rhs)
+		return BoolValue(l.num > r.num)
 	case token.GREATER_EQUAL:
-		ip.operands.Push(lhs >= rhs)
+		return BoolValue(l.num >= r.num)
 	case token.LESS:
-		ip.operands.Push(lhs < rhs)
+		return BoolValue(l.num < r.num)
 	case token.LESS_EQUAL:
-		ip.operands.Push(lhs <= rhs)
+		return BoolValue(l.num <= r.num)
 	}
+
+	panic(fmt.Errorf("Unexpected binary operator %s", expr.Operator.Type))
 }
 
-func (ip *interpreter) handleGroupingExpr(expr *ast.GroupingExpr) {
-	ast.Walk(ip, expr.Group)
+func (ip *interpreter) handleCallExpr(expr *ast.CallExpr) Value {
+	callee := ip.evaluate(expr.Callee)
+
+	args := make([]Value, len(expr.Args))
+	for i, arg := range expr.Args {
+		args[i] = ip.evaluate(arg)
+	}
+
+	fn, ok := callee.AsObject().(Callable)
+	if !ok {
+		panic(newError(KindRuntime, expr.Paren, "Can only call functions and classes"))
+	}
+
+	if len(args) != fn.Arity() {
+		panic(newError(KindRuntime, expr.Paren, fmt.Sprintf("Expected %d arguments but got %d", fn.Arity(), len(args))))
+	}
+
+	if ip.maxCallDepth > 0 && ip.calls.Len() >= ip.maxCallDepth {
+		panic(newError(KindStackOverflow, expr.Paren, "Stack overflow"))
+	}
+
+	ip.calls.Push(call{callee: fn, paren: expr.Paren})
+	value := fn.call(ip, expr.Paren, args)
+	ip.calls.Pop()
+
+	return value
 }
 
-func (ip *interpreter) handleLiteralExpr(expr *ast.LiteralExpr) {
-	switch value := expr.Value; value.Type {
-	case token.NIL:
-		ip.operands.Push(nil)
+func (ip *interpreter) handleGetExpr(expr *ast.GetExpr) Value {
+	instance, ok := ip.evaluate(expr.Object).AsObject().(*Instance)
+	if !ok {
+		panic(newError(KindRuntime, expr.Name, "Only instances have properties"))
+	}
 
+	if value, ok := instance.Get(expr.Name.Lexeme); ok {
+		return value
+	}
+
+	panic(newError(KindRuntime, expr.Name, fmt.Sprintf("Undefined property '%s'", expr.Name.Lexeme)))
+}
+
+func (ip *interpreter) handleLiteralExpr(expr *ast.LiteralExpr) Value {
+	switch value := expr.Value; value.Type {
 	case token.TRUE:
-		ip.operands.Push(true)
+		return BoolValue(true)
 
 	case token.FALSE:
-		ip.operands.Push(false)
+		return BoolValue(false)
 
 	case token.STRING:
-		ip.operands.Push(value.Lexeme[1 : len(value.Lexeme)-1])
+		return StringValue(value.Lexeme[1 : len(value.Lexeme)-1])
 
 	case token.NUMBER:
 		number, err := strconv.ParseFloat(value.Lexeme, 64)
 		if err != nil {
-			panic(&Error{"Invalid number literal", value.Line})
+			panic(newError(KindRuntime, value, "Invalid number literal"))
 		}
-		ip.operands.Push(number)
+		return NumberValue(number)
 	}
+
+	return Value{}
 }
 
-func (ip *interpreter) handleUnaryExpr(expr *ast.UnaryExpr) {
+func (ip *interpreter) handleLogicalExpr(expr *ast.LogicalExpr) Value {
+	l := ip.evaluate(expr.Left)
+
+	if expr.Operator.Type == token.OR {
+		if l.Truthy() {
+			return l
+		}
+	} else if !l.Truthy() {
+		return l
+	}
+
+	return ip.evaluate(expr.Right)
+}
+
+func (ip *interpreter) handleSetExpr(expr *ast.SetExpr) Value {
+	instance, ok := ip.evaluate(expr.Object).AsObject().(*Instance)
+	if !ok {
+		panic(newError(KindRuntime, expr.Name, "Only instances have fields"))
+	}
+
+	value := ip.evaluate(expr.Value)
+	instance.Set(expr.Name.Lexeme, value)
+	return value
+}
+
+func (ip *interpreter) handleSuperExpr(expr *ast.SuperExpr) Value {
+	superclass := ip.env.GetAt(expr.Depth, expr.Keyword.Lexeme).AsObject().(*Class)
+
+	// The instance is always bound one scope inside the superclass binding
+	instance := ip.env.GetAt(expr.Depth-1, "this").AsObject().(*Instance)
+
+	if method, ok := superclass.findMethod(expr.Method.Lexeme); ok {
+		return ObjectValue(method.bind(instance))
+	}
+
+	panic(newError(KindRuntime, expr.Method, fmt.Sprintf("Undefined property '%s'", expr.Method.Lexeme)))
+}
+
+func (ip *interpreter) handleThisExpr(expr *ast.ThisExpr) Value {
+	return ip.env.GetAt(expr.Depth, expr.Keyword.Lexeme)
+}
+
+func (ip *interpreter) handleUnaryExpr(expr *ast.UnaryExpr) Value {
 	r := ip.evaluate(expr.Right)
 
 	switch expr.Operator.Type {
 	case token.BANG:
-		ip.operands.Push(!isTruthy(r))
+		return BoolValue(!r.Truthy())
 
 	case token.MINUS:
-		if rhs, ok := r.(float64); ok {
-			ip.operands.Push(-rhs)
-			return
+		if r.typ == TypeNumber {
+			return NumberValue(-r.num)
 		}
-		panic(&Error{"Operand must be a number", expr.Operator.Line})
+		panic(newError(KindRuntime, expr.Operator, "Operand must be a number"))
 	}
+
+	panic(fmt.Errorf("Unexpected unary operator %s", expr.Operator.Type))
 }
 
-func (ip *interpreter) handleVariableExpr(expr *ast.VariableExpr) {
-	if value, ok := ip.env.Get(expr.Name.Lexeme); ok {
-		ip.operands.Push(value)
-		return
+func (ip *interpreter) handleVariableExpr(expr *ast.VariableExpr) Value {
+	if expr.Depth >= 0 {
+		return ip.env.GetAt(expr.Depth, expr.Name.Lexeme)
+	}
+
+	if value, ok := ip.globals.Get(expr.Name.Lexeme); ok {
+		return value
 	}
 
-	panic(&Error{
-		Msg:  fmt.Sprintf("Undefined variable '%s'", expr.Name.Lexeme),
-		Line: expr.Name.Line,
-	})
+	panic(newError(KindRuntime, expr.Name, fmt.Sprintf("Undefined variable '%s'", expr.Name.Lexeme)))
 }
@@ -0,0 +1,480 @@
+package lox_test
+
+import (
+	"context"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kevhlee/glox/pkg/lox"
+	"github.com/kevhlee/glox/pkg/parser"
+)
+
+// TestControlFlow checks that the interpreter executes conditionals, logical
+// expressions and loops.
+func TestControlFlow(t *testing.T) {
+	testRun(t, `
+if (nil) print "then"; else print "else";
+print nil or "or";
+print 1 and 2;
+for (var i = 0; i < 3; i = i + 1) print i;
+var j = 0;
+while (j < 2) j = j + 1;
+print j;
+`, "else\nor\n2\n0\n1\n2\n2\n")
+}
+
+// TestFunctions checks that the interpreter calls functions and returns from
+// them, including from inside loops.
+func TestFunctions(t *testing.T) {
+	testRun(t, `
+fun add(a, b) { return a + b; }
+fun none() {}
+fun early() { return; print "unreachable"; }
+
+fun find(n) {
+  for (var i = 0; ; i = i + 1) {
+    if (i * i >= n) return i;
+  }
+}
+
+fun fib(n) {
+  if (n < 2) return n;
+  return fib(n - 1) + fib(n - 2);
+}
+
+print add(1, 2);
+print none();
+print early();
+print find(10);
+print fib(10);
+print add;
+`, "3\nnil\nnil\n4\n55\n<fn add>\n")
+
+	testRunError(t, "fun f(a, b) {} f(1);", "Expected 2 arguments but got 1")
+	testRunError(t, `"f"();`, "Can only call functions and classes")
+	testRunError(t, "return 1;", "Can't return from top-level code")
+}
+
+// TestClosures checks that functions close over the environment they were
+// declared in.
+func TestClosures(t *testing.T) {
+	testRun(t, `
+fun makeCounter() {
+  var i = 0;
+  fun count() {
+    i = i + 1;
+    return i;
+  }
+  return count;
+}
+
+var a = makeCounter();
+var b = makeCounter();
+print a();
+print a();
+print b();
+
+var x = "global";
+{
+  fun show() { print x; }
+  show();
+  var x = "local";
+  show();
+}
+`, "1\n2\n1\nglobal\nglobal\n")
+}
+
+// TestClosureEnvironments checks that each call gets a fresh environment, and
+// that closures declared in the same call share the variables they capture.
+func TestClosureEnvironments(t *testing.T) {
+	testRun(t, `
+var get;
+var set;
+
+fun makeCell(value) {
+  fun getter() { return value; }
+  fun setter(v) { value = v; }
+  get = getter;
+  set = setter;
+}
+
+makeCell(1);
+var oldGet = get;
+makeCell(2);
+set(3);
+print oldGet();
+print get();
+
+fun adder(n) {
+  fun add(x) { return x + n; }
+  return add;
+}
+
+var add1 = adder(1);
+var add10 = adder(10);
+print add1(5);
+print add10(5);
+print adder(100)(5);
+`, "1\n3\n6\n15\n105\n")
+}
+
+// TestClasses checks that the interpreter supports classes, initializers,
+// inheritance and 'super' calls.
+func TestClasses(t *testing.T) {
+	testRun(t, `
+class A {
+  init(name) { this.name = name; }
+  greet() { return "Hello, " + this.name; }
+}
+
+class B < A {
+  greet() { return super.greet() + "!"; }
+}
+
+var b = B("Lox");
+print b.greet();
+print b;
+print B;
+`, "Hello, Lox!\nB instance\nB\n")
+}
+
+// TestInstances checks that instances have fields, that methods are bound to
+// their instance, and that initializers always return 'this'.
+func TestInstances(t *testing.T) {
+	testRun(t, `
+class Counter {
+  init(start) {
+    this.count = start;
+    if (start > 10) return;
+    this.small = true;
+  }
+  increment() {
+    this.count = this.count + 1;
+    return this;
+  }
+}
+
+var c = Counter(1);
+print c.increment().increment().count;
+print c.small;
+
+var increment = c.increment;
+increment();
+print c.count;
+
+c.count = "field";
+print c.count;
+
+var big = Counter(20);
+print big.init(5) == big;
+print big.count;
+
+class Empty {}
+print Empty();
+`, "3\ntrue\n4\nfield\ntrue\n5\nEmpty instance\n")
+
+	testRunError(t, "class A { init(a) {} } A();", "Expected 1 arguments but got 0")
+	testRunError(t, "class A {} A(1);", "Expected 0 arguments but got 1")
+	testRunError(t, "class A {} print A().x;", "Undefined property 'x'")
+	testRunError(t, `print "a".x;`, "Only instances have properties")
+	testRunError(t, "var a = 1; a.x = 2;", "Only instances have fields")
+	testRunError(t, "class A { init() { return 1; } }", "Can't return a value from an initializer")
+	testRunError(t, "print this;", "Can't use 'this' outside of a class")
+}
+
+// TestInheritance checks that methods are looked up the superclass chain, and
+// that 'super' refers to the superclass of the class the method is declared
+// in, not of the instance.
+func TestInheritance(t *testing.T) {
+	testRun(t, `
+class A {
+  init() { this.name = "A"; }
+  method() { return "A.method"; }
+  who() { return "A"; }
+}
+
+class B < A {
+  who() { return "B/" + super.who(); }
+  test() { return super.who(); }
+}
+
+class C < B {
+  who() { return "C/" + super.who(); }
+}
+
+var c = C();
+print c.name;
+print c.method();
+print c.who();
+print c.test();
+
+fun superMethod() {
+  class D < A {
+    get() { return super.method; }
+  }
+  return D().get();
+}
+print superMethod()();
+`, "A\nA.method\nC/B/A\nA\nA.method\n")
+
+	testRunError(t, "class A < A {}", "A class can't inherit from itself")
+	testRunError(t, "class A { f() { super.f(); } }", "Can't use 'super' in a class with no superclass")
+	testRunError(t, "print super.f;", "Can't use 'super' outside of a class")
+	testRunError(t, "var A = 1; class B < A {}", "Superclass must be a class")
+	testRunError(t, "class A {} class B < A { f() { super.g(); } } B().f();", "Undefined property 'g'")
+}
+
+// TestRunErrors checks that the interpreter returns and reports compile and
+// runtime errors.
+func TestRunErrors(t *testing.T) {
+	var stderr strings.Builder
+
+	in := lox.NewInterpreter(lox.WithStderr(&stderr))
+
+	err := in.Run("print ;")
+	if _, ok := err.(parser.ErrorList); !ok {
+		t.Errorf("Expected a compile error, got '%v' instead", err)
+	}
+
+	err = in.Run(`
+-"a";`)
+	if err, ok := err.(*lox.Error); !ok {
+		t.Errorf("Expected a runtime error, got '%v' instead", err)
+	} else if err.Line != 2 {
+		t.Errorf("Expected runtime error on line 2, got %d instead", err.Line)
+	}
+
+	expected := `[line 1] Error at ';': Expect expression.
+  |
+1 | print ;
+  |       ^
+Operand must be a number.
+[line 2]
+  |
+2 | -"a";
+  | ^
+`
+	if actual := stderr.String(); actual != expected {
+		t.Errorf("\nExpected:\n%s\nActual:\n%s\n", expected, actual)
+	}
+}
+
+// TestRunInteractive checks that interactive input prints the value of a bare
+// expression, and otherwise runs like a program.
+func TestRunInteractive(t *testing.T) {
+	var stdout strings.Builder
+
+	in := lox.NewInterpreter(lox.WithStdout(&stdout))
+
+	for _, input := range []string{"var a = 1;", "a + 2", "a = nil", `print "a";`, "a;"} {
+		if err := in.RunInteractive(input); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if actual, expected := stdout.String(), "3\nnil\na\n"; actual != expected {
+		t.Errorf("\nExpected:\n%s\nActual:\n%s\n", expected, actual)
+	}
+}
+
+// TestRunLimits checks that execution is aborted when its context is cancelled
+// or its step budget is exceeded.
+func TestRunLimits(t *testing.T) {
+	source := `
+var i = 0;
+while (true) {
+  i = i + 1;
+}
+`
+
+	in := lox.NewInterpreter(lox.WithStderr(io.Discard), lox.WithStepLimit(10000))
+
+	if err, ok := in.Run(source).(*lox.Error); !ok {
+		t.Errorf("Expected a runtime error, got '%v' instead", err)
+	} else if err.Kind != lox.KindBudgetExceeded {
+		t.Errorf("Expected error kind %d, got %d instead", lox.KindBudgetExceeded, err.Kind)
+	} else if err.Line < 3 {
+		t.Errorf("Expected runtime error inside the loop, got line %d instead", err.Line)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	in = lox.NewInterpreter(lox.WithStderr(io.Discard))
+
+	if err, ok := in.RunContext(ctx, source).(*lox.Error); !ok {
+		t.Errorf("Expected a runtime error, got '%v' instead", err)
+	} else if err.Kind != lox.KindCancelled {
+		t.Errorf("Expected error kind %d, got %d instead", lox.KindCancelled, err.Kind)
+	}
+}
+
+// TestRunSandboxLimits checks that deep recursion and unbounded allocations
+// raise runtime errors instead of crashing the process.
+func TestRunSandboxLimits(t *testing.T) {
+	tests := []struct {
+		source string
+		opts   []lox.Option
+		kind   lox.ErrorKind
+		msg    string
+	}{
+		{
+			source: "fun f() { f(); } f();",
+			kind:   lox.KindStackOverflow,
+			msg:    "Stack overflow",
+		},
+		{
+			source: "fun f(n) { if (n > 0) f(n - 1); } f(100);",
+			opts:   []lox.Option{lox.WithMaxCallDepth(50)},
+			kind:   lox.KindStackOverflow,
+			msg:    "Stack overflow",
+		},
+		{
+			source: `var s = "a"; while (true) s = s + s;`,
+			opts:   []lox.Option{lox.WithAllocationLimit(1 << 20)},
+			kind:   lox.KindOutOfMemory,
+			msg:    "Out of memory",
+		},
+	}
+
+	for _, test := range tests {
+		in := lox.NewInterpreter(append(test.opts, lox.WithStderr(io.Discard))...)
+
+		if err, ok := in.Run(test.source).(*lox.Error); !ok {
+			t.Errorf("Expected a runtime error for '%s', got '%v' instead", test.source, err)
+		} else if err.Kind != test.kind || err.Msg != test.msg {
+			t.Errorf("Expected error '%s' for '%s', got '%s' instead", test.msg, test.source, err.Msg)
+		}
+	}
+}
+
+// TestRunStackOverflowLine checks that scopes nested too deeply are reported at
+// the block or call that opens them, even if their body is empty.
+func TestRunStackOverflowLine(t *testing.T) {
+	tests := []struct {
+		source string
+		trace  []lox.Frame
+	}{
+		{"{\n{\n{\n}\n}\n}", []lox.Frame{{Function: "script", Line: 3}}},
+		{"{\n{\nfun f() {}\n\nf();\n}\n}", []lox.Frame{{Function: "script", Line: 5}, {Function: "f", Line: 5}}},
+	}
+
+	for _, test := range tests {
+		in := lox.NewInterpreter(lox.WithStderr(io.Discard), lox.WithMaxEnvDepth(2))
+
+		err, ok := in.Run(test.source).(*lox.Error)
+		if !ok || err.Kind != lox.KindStackOverflow {
+			t.Errorf("Expected a stack overflow for %q, got '%v' instead", test.source, err)
+			continue
+		}
+
+		line := test.trace[len(test.trace)-1].Line
+		if err.Line != line || err.Token == nil || err.Token.Line != line {
+			t.Errorf("Expected stack overflow on line %d for %q, got %d instead", line, test.source, err.Line)
+		}
+		if !slices.Equal(err.Trace, test.trace) {
+			t.Errorf("Expected trace %v for %q, got %v instead", test.trace, test.source, err.Trace)
+		}
+	}
+}
+
+// TestRunNestingLimit checks that deeply nested expressions and statements are
+// compile errors instead of crashing the process.
+func TestRunNestingLimit(t *testing.T) {
+	const n = 10000
+
+	sources := []string{
+		"print " + strings.Repeat("(", n) + "1" + strings.Repeat(")", n) + ";",
+		"print 1" + strings.Repeat(" + 1", n) + ";",
+		"print " + strings.Repeat("!", n) + "true;",
+		strings.Repeat("{", n) + strings.Repeat("}", n),
+		strings.Repeat("if (true) ", n) + "print 1;",
+		"fun f() { return f; } f" + strings.Repeat("()", n) + ";",
+	}
+
+	for _, source := range sources {
+		in := lox.NewInterpreter(lox.WithStderr(io.Discard))
+
+		if errs, ok := in.Run(source).(parser.ErrorList); !ok || len(errs) != 1 {
+			t.Errorf("Expected a single compile error for '%.20s...', got '%v' instead", source, errs)
+		} else if expected := "Can't nest more than 1000 expressions or statements"; errs[0].Msg != expected {
+			t.Errorf("Expected error '%s' for '%.20s...', got '%s' instead", expected, source, errs[0].Msg)
+		}
+	}
+
+	testRun(t, "print "+strings.Repeat("(", 100)+"1"+strings.Repeat(")", 100)+";", "1\n")
+}
+
+// TestRunTrace checks that runtime errors raised inside calls carry and report
+// a stack trace.
+func TestRunTrace(t *testing.T) {
+	var stderr strings.Builder
+
+	in := lox.NewInterpreter(lox.WithStderr(&stderr))
+
+	err, ok := in.Run(`fun inner() {
+  return -"a";
+}
+fun outer() {
+  inner();
+}
+outer();`).(*lox.Error)
+	if !ok {
+		t.Fatalf("Expected a runtime error, got '%v' instead", err)
+	}
+
+	expected := []lox.Frame{
+		{Function: "script", Line: 7},
+		{Function: "outer", Line: 5},
+		{Function: "inner", Line: 2},
+	}
+	if len(err.Trace) != len(expected) {
+		t.Fatalf("Expected %d frames, got %d instead", len(expected), len(err.Trace))
+	}
+	for i := range expected {
+		if err.Trace[i] != expected[i] {
+			t.Errorf("Expected frame '%v', got '%v' instead", expected[i], err.Trace[i])
+		}
+	}
+
+	traceback := `Traceback (most recent call last):
+  line 7, in script
+  line 5, in outer()
+  line 2, in inner()
+Operand must be a number.
+[line 2]
+`
+	if actual := stderr.String(); !strings.HasPrefix(actual, traceback) {
+		t.Errorf("\nExpected prefix:\n%s\nActual:\n%s\n", traceback, actual)
+	}
+}
+
+func testRun(t *testing.T, source, expected string) {
+	var stdout strings.Builder
+
+	in := lox.NewInterpreter(lox.WithStdout(&stdout), lox.WithStderr(&stdout))
+
+	if err := in.Run(source); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := stdout.String(); actual != expected {
+		t.Errorf("\nExpected:\n%s\nActual:\n%s\n", expected, actual)
+	}
+}
+
+func testRunError(t *testing.T, source, expected string) {
+	in := lox.NewInterpreter(lox.WithStderr(io.Discard))
+
+	err := in.Run(source)
+	if err == nil {
+		t.Fatalf("Expected error '%s', got none", expected)
+	}
+
+	if actual := err.Error(); actual != expected {
+		t.Errorf("Expected error '%s', got '%s' instead", expected, actual)
+	}
+}
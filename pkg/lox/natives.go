@@ -0,0 +1,13 @@
+package lox
+
+import "time"
+
+// Defines the standard native functions of Lox.
+func defineNatives(env *Environment) {
+	env.DefineNative("clock", 0, nativeClock)
+}
+
+// Returns the number of seconds since the Unix epoch.
+func nativeClock(args []Value) (Value, error) {
+	return NumberValue(float64(time.Now().UnixNano()) / float64(time.Second)), nil
+}
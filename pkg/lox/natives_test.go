@@ -0,0 +1,46 @@
+package lox_test
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/kevhlee/glox/pkg/lox"
+)
+
+// TestDefineNative checks that native functions can be called from Lox with
+// their arguments, and that their errors are raised as runtime errors.
+func TestDefineNative(t *testing.T) {
+	globals := lox.NewGlobalEnvironment()
+
+	var actual []lox.Value
+	globals.DefineNative("record", 2, func(args []lox.Value) (lox.Value, error) {
+		actual = append(actual, args...)
+		return lox.Value{}, nil
+	})
+	globals.DefineNative("fail", 0, func(args []lox.Value) (lox.Value, error) {
+		return lox.Value{}, fmt.Errorf("Failed")
+	})
+
+	if status := lox.RunSource(globals, `record(1, "a"); record(clock() > 0, nil);`); status != lox.ExitOK {
+		t.Fatalf("Expected exit status %d, got %d instead", lox.ExitOK, status)
+	}
+
+	expected := []lox.Value{lox.NumberValue(1), lox.StringValue("a"), lox.BoolValue(true), {}}
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %d arguments, got %d instead", len(expected), len(actual))
+	}
+	for i := range expected {
+		if !actual[i].Equal(expected[i]) {
+			t.Errorf("Expected argument '%v', got '%v' instead", expected[i], actual[i])
+		}
+	}
+
+	in := lox.NewInterpreter(lox.WithGlobals(globals), lox.WithStderr(io.Discard))
+
+	if err, ok := in.Run("\nfail();").(*lox.Error); !ok {
+		t.Errorf("Expected a runtime error, got '%v' instead", err)
+	} else if err.Line != 2 {
+		t.Errorf("Expected runtime error on line 2, got %d instead", err.Line)
+	}
+}
@@ -0,0 +1,181 @@
+package lox
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ValueType is the type of a Lox value.
+type ValueType uint8
+
+const (
+	// TypeNil is the type of nil.
+	TypeNil ValueType = iota
+
+	// TypeBool is the type of true and false.
+	TypeBool
+
+	// TypeNumber is the type of numbers, which are double-precision floats.
+	TypeNumber
+
+	// TypeString is the type of strings.
+	TypeString
+
+	// TypeObject is the type of values stored by reference (e.g. functions,
+	// classes and instances), which are implemented by an [Object].
+	TypeObject
+)
+
+// Object is a Lox value stored by reference.
+//
+// Objects are only equal to themselves. Backends may define their own objects
+// (e.g. compiled functions) alongside those of the tree-walking interpreter.
+//
+// Implementations must be pointer types, since objects are compared (and used
+// as map keys) by identity. Other types may not be comparable, in which case
+// comparing them panics.
+type Object interface {
+	// String returns how the object is printed.
+	String() string
+
+	// TypeName returns the name of the object's type (e.g. "function").
+	TypeName() string
+}
+
+// Value is a Lox value.
+//
+// Numbers and booleans are stored without allocating, so arithmetic does not
+// allocate. In exchange, a value takes 32 bytes, twice as much as an
+// interface, which makes environments and stacks of values larger. The zero
+// value is nil.
+type Value struct {
+	typ ValueType
+
+	// The number, or 1 for true and 0 for false
+	num float64
+
+	// The string or [Object]
+	ref any
+}
+
+// BoolValue returns a boolean value.
+func BoolValue(b bool) Value {
+	if b {
+		return Value{typ: TypeBool, num: 1}
+	}
+	return Value{typ: TypeBool}
+}
+
+// NumberValue returns a number value.
+func NumberValue(n float64) Value {
+	return Value{typ: TypeNumber, num: n}
+}
+
+// StringValue returns a string value.
+func StringValue(s string) Value {
+	return Value{typ: TypeString, ref: s}
+}
+
+// ObjectValue returns a value stored by reference, or nil if the object is nil.
+func ObjectValue(obj Object) Value {
+	if obj == nil {
+		return Value{}
+	}
+	return Value{typ: TypeObject, ref: obj}
+}
+
+// Type returns the type of the value.
+func (v Value) Type() ValueType {
+	return v.typ
+}
+
+// IsNil reports whether the value is nil.
+func (v Value) IsNil() bool {
+	return v.typ == TypeNil
+}
+
+// AsBool returns the value as a boolean, or false if it is not a boolean.
+func (v Value) AsBool() bool {
+	return v.typ == TypeBool && v.num != 0
+}
+
+// AsNumber returns the value as a number, or 0 if it is not a number.
+func (v Value) AsNumber() float64 {
+	if v.typ != TypeNumber {
+		return 0
+	}
+	return v.num
+}
+
+// AsString returns the value as a string, or "" if it is not a string.
+//
+// Unlike [Value.String], this function does not convert other types of values.
+func (v Value) AsString() string {
+	s, _ := v.ref.(string)
+	return s
+}
+
+// AsObject returns the value as an object, or nil if it is not an object.
+func (v Value) AsObject() Object {
+	obj, _ := v.ref.(Object)
+	return obj
+}
+
+// Truthy reports whether the value is considered true in a condition. Only nil
+// and false are falsey.
+func (v Value) Truthy() bool {
+	switch v.typ {
+	case TypeNil:
+		return false
+	case TypeBool:
+		return v.num != 0
+	default:
+		return true
+	}
+}
+
+// Equal reports whether two values are equal.
+//
+// Values of different types are never equal. Strings are equal if they have
+// the same contents, and objects only if they are the same object. Like in
+// IEEE 754, NaN is not equal to itself.
+func (v Value) Equal(other Value) bool {
+	return v.typ == other.typ && v.num == other.num && v.ref == other.ref
+}
+
+// String implements the [fmt.Stringer] interface, returning how the value is
+// printed.
+func (v Value) String() string {
+	switch v.typ {
+	case TypeNil:
+		return "nil"
+	case TypeBool:
+		return strconv.FormatBool(v.num != 0)
+	case TypeNumber:
+		return strconv.FormatFloat(v.num, 'g', -1, 64)
+	case TypeString:
+		return v.ref.(string)
+	case TypeObject:
+		return v.ref.(Object).String()
+	}
+
+	panic(fmt.Errorf("Unexpected value type %d", v.typ))
+}
+
+// TypeName returns the name of the value's type (e.g. "number").
+func (v Value) TypeName() string {
+	switch v.typ {
+	case TypeNil:
+		return "nil"
+	case TypeBool:
+		return "boolean"
+	case TypeNumber:
+		return "number"
+	case TypeString:
+		return "string"
+	case TypeObject:
+		return v.ref.(Object).TypeName()
+	}
+
+	panic(fmt.Errorf("Unexpected value type %d", v.typ))
+}
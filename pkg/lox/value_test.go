@@ -0,0 +1,71 @@
+package lox_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kevhlee/glox/pkg/lox"
+)
+
+// TestValue checks the truthiness, printed form and type name of each type of
+// value.
+func TestValue(t *testing.T) {
+	tests := []struct {
+		value    lox.Value
+		truthy   bool
+		str      string
+		typeName string
+	}{
+		{lox.Value{}, false, "nil", "nil"},
+		{lox.BoolValue(false), false, "false", "boolean"},
+		{lox.BoolValue(true), true, "true", "boolean"},
+		{lox.NumberValue(0), true, "0", "number"},
+		{lox.NumberValue(-2.5), true, "-2.5", "number"},
+		{lox.NumberValue(1e21), true, "1e+21", "number"},
+		{lox.StringValue(""), true, "", "string"},
+		{lox.StringValue("lox"), true, "lox", "string"},
+		{lox.ObjectValue(&lox.NativeFunc{}), true, "<native fn>", "function"},
+	}
+
+	for _, test := range tests {
+		if truthy := test.value.Truthy(); truthy != test.truthy {
+			t.Errorf("Expected truthiness of %v to be %t, got %t instead", test.value, test.truthy, truthy)
+		}
+		if str := test.value.String(); str != test.str {
+			t.Errorf("Expected %q, got %q instead", test.str, str)
+		}
+		if typeName := test.value.TypeName(); typeName != test.typeName {
+			t.Errorf("Expected type name of %v to be %q, got %q instead", test.value, test.typeName, typeName)
+		}
+	}
+}
+
+// TestValueEqual checks that values are only equal to values of the same type
+// and contents, and objects only to themselves.
+func TestValueEqual(t *testing.T) {
+	fn := &lox.NativeFunc{}
+
+	tests := []struct {
+		l, r  lox.Value
+		equal bool
+	}{
+		{lox.Value{}, lox.Value{}, true},
+		{lox.Value{}, lox.BoolValue(false), false},
+		{lox.BoolValue(true), lox.BoolValue(true), true},
+		{lox.BoolValue(true), lox.NumberValue(1), false},
+		{lox.NumberValue(1), lox.NumberValue(1), true},
+		{lox.NumberValue(0), lox.NumberValue(math.Copysign(0, -1)), true},
+		{lox.NumberValue(math.NaN()), lox.NumberValue(math.NaN()), false},
+		{lox.NumberValue(1), lox.StringValue("1"), false},
+		{lox.StringValue("a" + "b"), lox.StringValue("ab"), true},
+		{lox.ObjectValue(fn), lox.ObjectValue(fn), true},
+		{lox.ObjectValue(fn), lox.ObjectValue(&lox.NativeFunc{}), false},
+		{lox.ObjectValue(nil), lox.Value{}, true},
+	}
+
+	for _, test := range tests {
+		if equal := test.l.Equal(test.r); equal != test.equal {
+			t.Errorf("Expected %v == %v to be %t, got %t instead", test.l, test.r, test.equal, equal)
+		}
+	}
+}
@@ -9,6 +9,26 @@ import (
 
 // ParseSource converts Lox source code into an AST.
 func ParseSource(source string) ([]ast.Stmt, error) {
+	return newParser(source).parse()
+}
+
+// ParseInteractive converts input from an interactive session into an AST.
+//
+// The input may either be a program, or a single expression without a
+// trailing semicolon. If the input is a single expression, it is returned
+// instead of a list of statements.
+func ParseInteractive(source string) ([]ast.Stmt, ast.Expr, error) {
+	p := newParser(source)
+
+	if expr := p.parseExpression(); expr != nil {
+		return nil, expr, nil
+	}
+
+	body, err := p.parse()
+	return body, nil, err
+}
+
+func newParser(source string) *parser {
 	var p parser
 
 	for _, tok := range scanner.ScanSource(source) {
@@ -19,5 +39,33 @@ func ParseSource(source string) ([]ast.Stmt, error) {
 		}
 	}
 
-	return p.parse()
+	return &p
+}
+
+// IsIncomplete checks if input from an interactive session is incomplete,
+// meaning that it has no errors other than those caused by reaching the end of
+// the input too early (e.g. an unclosed block or an unterminated string).
+//
+// Like [ParseInteractive], a single expression without a trailing semicolon is
+// complete.
+//
+// This is useful for interactive sessions to decide whether to wait for more
+// input before executing the source code.
+func IsIncomplete(source string) bool {
+	_, _, err := ParseInteractive(source)
+
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) == 0 {
+		return false
+	}
+
+	// Scanner errors are listed first, regardless of their position
+	switch first := errs[0].Token; first.Type {
+	case token.EOF:
+		return true
+	case token.ERROR:
+		return first.End == len(source) && source[first.Start] == '"'
+	default:
+		return false
+	}
 }
@@ -1,21 +1,42 @@
 package parser
 
 import (
+	"fmt"
 	"slices"
 
 	"github.com/kevhlee/glox/pkg/ast"
 	"github.com/kevhlee/glox/pkg/token"
 )
 
+// The maximum number of parameters or arguments of a function.
+const maxArgs = 255
+
+// The maximum depth of nested expressions and statements. The AST is walked
+// recursively after it is parsed, so deeper nesting could overflow the Go stack
+// and crash the process.
+const maxDepth = 1000
+
 // Contains the internal state and logic of the parser.
 type parser struct {
 	tokens  []*token.Token
 	errors  ErrorList
 	current int
+
+	// The depth of the expression or statement being parsed
+	depth int
 }
 
-func (p *parser) parse() ([]ast.Stmt, error) {
-	var result []ast.Stmt
+func (p *parser) parse() (result []ast.Stmt, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if r, ok := r.(nestingError); ok {
+				p.errors = append(p.errors, r.err)
+				result, err = nil, p.errors.Err()
+			} else {
+				panic(r)
+			}
+		}
+	}()
 
 	for p.isParsing() {
 		if decl := p.declaration(); decl != nil {
@@ -26,6 +47,36 @@ func (p *parser) parse() ([]ast.Stmt, error) {
 	return result, p.errors.Err()
 }
 
+// Parses the tokens as a single expression, returning nil without consuming
+// any tokens or recording any errors if that is not possible.
+func (p *parser) parseExpression() (expr ast.Expr) {
+	if len(p.errors) > 0 {
+		return nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch r.(type) {
+			case *Error, nestingError:
+				expr = nil
+			default:
+				panic(r)
+			}
+		}
+
+		if expr == nil || len(p.errors) > 0 {
+			expr = nil
+			p.current = 0
+			p.errors = nil
+		}
+	}()
+
+	if expr = p.expression(); p.isParsing() {
+		return nil
+	}
+	return expr
+}
+
 func (p *parser) peek() *token.Token {
 	return p.tokens[p.current]
 }
@@ -64,6 +115,26 @@ func (p *parser) expect(expected token.Type, msg string) *token.Token {
 	panic(&Error{msg, p.peek()})
 }
 
+// Raised when expressions or statements are nested too deeply. Unlike other
+// syntax errors, this aborts parsing, since none of the enclosing expressions
+// and statements can be completed.
+type nestingError struct {
+	err *Error
+}
+
+// Enters a nested expression or statement, which must be left by calling
+// leave.
+func (p *parser) enter() {
+	if p.depth >= maxDepth {
+		panic(nestingError{&Error{fmt.Sprintf("Can't nest more than %d expressions or statements", maxDepth), p.peek()}})
+	}
+	p.depth++
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
 func (p *parser) synchronize() {
 	p.advance()
 
@@ -97,6 +168,14 @@ func (p *parser) declaration() ast.Stmt {
 		}
 	}()
 
+	if p.match(token.CLASS) {
+		return p.classDeclaration()
+	}
+
+	if p.match(token.FUN) {
+		return p.function("function")
+	}
+
 	if p.match(token.VAR) {
 		return p.varDeclaration()
 	}
@@ -104,6 +183,53 @@ func (p *parser) declaration() ast.Stmt {
 	return p.statement()
 }
 
+func (p *parser) classDeclaration() *ast.ClassStmt {
+	name := p.expect(token.IDENTIFIER, "Expect class name")
+
+	var superclass *ast.VariableExpr
+	if p.match(token.LESS) {
+		superclass = &ast.VariableExpr{Name: p.expect(token.IDENTIFIER, "Expect superclass name")}
+	}
+
+	p.expect(token.LEFT_BRACE, "Expect '{' before class body")
+
+	var methods []*ast.FunctionStmt
+	for p.isParsing() && !p.check(token.RIGHT_BRACE) {
+		methods = append(methods, p.function("method"))
+	}
+	p.expect(token.RIGHT_BRACE, "Expect '}' after class body")
+
+	return &ast.ClassStmt{Name: name, Superclass: superclass, Methods: methods}
+}
+
+func (p *parser) function(kind string) *ast.FunctionStmt {
+	p.enter()
+	defer p.leave()
+
+	name := p.expect(token.IDENTIFIER, fmt.Sprintf("Expect %s name", kind))
+	p.expect(token.LEFT_PAREN, fmt.Sprintf("Expect '(' after %s name", kind))
+
+	var params []*token.Token
+	if !p.check(token.RIGHT_PAREN) {
+		for {
+			if len(params) >= maxArgs {
+				p.errors = append(p.errors, &Error{fmt.Sprintf("Can't have more than %d parameters", maxArgs), p.peek()})
+			}
+			params = append(params, p.expect(token.IDENTIFIER, "Expect parameter name"))
+
+			if !p.match(token.COMMA) {
+				break
+			}
+		}
+	}
+	p.expect(token.RIGHT_PAREN, "Expect ')' after parameters")
+
+	p.expect(token.LEFT_BRACE, fmt.Sprintf("Expect '{' before %s body", kind))
+	body := p.block()
+
+	return &ast.FunctionStmt{Name: name, Params: params, Body: body}
+}
+
 func (p *parser) varDeclaration() *ast.VarStmt {
 	name := p.expect(token.IDENTIFIER, "Expect variable name")
 
@@ -117,12 +243,31 @@ func (p *parser) varDeclaration() *ast.VarStmt {
 }
 
 func (p *parser) statement() ast.Stmt {
+	p.enter()
+	defer p.leave()
+
+	if p.match(token.FOR) {
+		return p.forStatement()
+	}
+
+	if p.match(token.IF) {
+		return p.ifStatement()
+	}
+
 	if p.match(token.PRINT) {
 		return p.printStatement()
 	}
 
+	if p.match(token.RETURN) {
+		return p.returnStatement()
+	}
+
+	if p.match(token.WHILE) {
+		return p.whileStatement()
+	}
+
 	if p.match(token.LEFT_BRACE) {
-		return &ast.BlockStmt{Body: p.block()}
+		return &ast.BlockStmt{Brace: p.previous(), Body: p.block()}
 	}
 
 	return p.expressionStatement()
@@ -137,12 +282,97 @@ func (p *parser) block() (body []ast.Stmt) {
 	return
 }
 
+// Parses a for loop by desugaring it into a while loop.
+func (p *parser) forStatement() ast.Stmt {
+	keyword := p.previous()
+	p.expect(token.LEFT_PAREN, "Expect '(' after 'for'")
+
+	var initializer ast.Stmt
+	if p.match(token.VAR) {
+		initializer = p.varDeclaration()
+	} else if !p.match(token.SEMICOLON) {
+		initializer = p.expressionStatement()
+	}
+
+	var condition ast.Expr
+	if !p.check(token.SEMICOLON) {
+		condition = p.expression()
+	}
+	p.expect(token.SEMICOLON, "Expect ';' after loop condition")
+
+	var increment ast.Expr
+	if !p.check(token.RIGHT_PAREN) {
+		increment = p.expression()
+	}
+	p.expect(token.RIGHT_PAREN, "Expect ')' after for clauses")
+
+	body := p.statement()
+
+	if increment != nil {
+		body = &ast.BlockStmt{Brace: keyword, Body: []ast.Stmt{body, &ast.ExpressionStmt{Expression: increment}}}
+	}
+
+	if condition == nil {
+		condition = &ast.LiteralExpr{Value: &token.Token{
+			Type:   token.TRUE,
+			Lexeme: "true",
+			Line:   keyword.Line,
+			Column: keyword.Column,
+			Start:  keyword.Start,
+			End:    keyword.End,
+		}}
+	}
+	body = &ast.WhileStmt{Condition: condition, Body: body}
+
+	if initializer != nil {
+		body = &ast.BlockStmt{Brace: keyword, Body: []ast.Stmt{initializer, body}}
+	}
+
+	return body
+}
+
+func (p *parser) ifStatement() *ast.IfStmt {
+	p.expect(token.LEFT_PAREN, "Expect '(' after 'if'")
+	condition := p.expression()
+	p.expect(token.RIGHT_PAREN, "Expect ')' after if condition")
+
+	// The else branch binds to the nearest if statement
+	then := p.statement()
+
+	var otherwise ast.Stmt
+	if p.match(token.ELSE) {
+		otherwise = p.statement()
+	}
+
+	return &ast.IfStmt{Condition: condition, Then: then, Else: otherwise}
+}
+
 func (p *parser) printStatement() *ast.PrintStmt {
 	value := p.expression()
 	p.expect(token.SEMICOLON, "Expect ';' after value")
 	return &ast.PrintStmt{Value: value}
 }
 
+func (p *parser) returnStatement() *ast.ReturnStmt {
+	keyword := p.previous()
+
+	var value ast.Expr
+	if !p.check(token.SEMICOLON) {
+		value = p.expression()
+	}
+	p.expect(token.SEMICOLON, "Expect ';' after return value")
+
+	return &ast.ReturnStmt{Keyword: keyword, Value: value}
+}
+
+func (p *parser) whileStatement() *ast.WhileStmt {
+	p.expect(token.LEFT_PAREN, "Expect '(' after 'while'")
+	condition := p.expression()
+	p.expect(token.RIGHT_PAREN, "Expect ')' after condition")
+
+	return &ast.WhileStmt{Condition: condition, Body: p.statement()}
+}
+
 func (p *parser) expressionStatement() *ast.ExpressionStmt {
 	expression := p.expression()
 	p.expect(token.SEMICOLON, "Expect ';' after expression")
@@ -154,16 +384,22 @@ func (p *parser) expressionStatement() *ast.ExpressionStmt {
 //
 
 func (p *parser) expression() ast.Expr {
+	p.enter()
+	defer p.leave()
+
 	return p.assignment()
 }
 
 func (p *parser) assignment() ast.Expr {
-	expr := p.equality()
+	expr := p.or()
 
 	if p.match(token.EQUAL) {
 		equal := p.previous()
-		if variable, ok := expr.(*ast.VariableExpr); ok {
-			return &ast.AssignExpr{Name: variable.Name, Value: p.expression()}
+		switch target := expr.(type) {
+		case *ast.VariableExpr:
+			return &ast.AssignExpr{Name: target.Name, Value: p.expression()}
+		case *ast.GetExpr:
+			return &ast.SetExpr{Object: target.Object, Name: target.Name, Value: p.expression()}
 		}
 		panic(&Error{"Invalid assignment target", equal})
 	}
@@ -171,9 +407,34 @@ func (p *parser) assignment() ast.Expr {
 	return expr
 }
 
+func (p *parser) or() ast.Expr {
+	expr := p.and()
+	for p.match(token.OR) {
+		p.enter()
+		defer p.leave()
+
+		expr = &ast.LogicalExpr{Left: expr, Operator: p.previous(), Right: p.and()}
+	}
+	return expr
+}
+
+func (p *parser) and() ast.Expr {
+	expr := p.equality()
+	for p.match(token.AND) {
+		p.enter()
+		defer p.leave()
+
+		expr = &ast.LogicalExpr{Left: expr, Operator: p.previous(), Right: p.equality()}
+	}
+	return expr
+}
+
 func (p *parser) equality() ast.Expr {
 	expr := p.comparison()
 	for p.match(token.BANG_EQUAL, token.EQUAL_EQUAL) {
+		p.enter()
+		defer p.leave()
+
 		expr = &ast.BinaryExpr{Left: expr, Operator: p.previous(), Right: p.comparison()}
 	}
 	return expr
@@ -182,6 +443,9 @@ func (p *parser) equality() ast.Expr {
 func (p *parser) comparison() ast.Expr {
 	expr := p.term()
 	for p.match(token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL) {
+		p.enter()
+		defer p.leave()
+
 		expr = &ast.BinaryExpr{Left: expr, Operator: p.previous(), Right: p.term()}
 	}
 	return expr
@@ -190,6 +454,9 @@ func (p *parser) comparison() ast.Expr {
 func (p *parser) term() ast.Expr {
 	expr := p.factor()
 	for p.match(token.PLUS, token.MINUS) {
+		p.enter()
+		defer p.leave()
+
 		expr = &ast.BinaryExpr{Left: expr, Operator: p.previous(), Right: p.factor()}
 	}
 	return expr
@@ -198,6 +465,9 @@ func (p *parser) term() ast.Expr {
 func (p *parser) factor() ast.Expr {
 	expr := p.unary()
 	for p.match(token.STAR, token.SLASH) {
+		p.enter()
+		defer p.leave()
+
 		expr = &ast.BinaryExpr{Left: expr, Operator: p.previous(), Right: p.unary()}
 	}
 	return expr
@@ -205,9 +475,47 @@ func (p *parser) factor() ast.Expr {
 
 func (p *parser) unary() ast.Expr {
 	if p.match(token.BANG, token.MINUS) {
+		p.enter()
+		defer p.leave()
+
 		return &ast.UnaryExpr{Operator: p.previous(), Right: p.unary()}
 	}
-	return p.primary()
+	return p.call()
+}
+
+func (p *parser) call() ast.Expr {
+	expr := p.primary()
+	for p.match(token.LEFT_PAREN, token.DOT) {
+		p.enter()
+		defer p.leave()
+
+		if p.previous().Type == token.LEFT_PAREN {
+			expr = p.finishCall(expr)
+		} else {
+			name := p.expect(token.IDENTIFIER, "Expect property name after '.'")
+			expr = &ast.GetExpr{Object: expr, Name: name}
+		}
+	}
+	return expr
+}
+
+func (p *parser) finishCall(callee ast.Expr) *ast.CallExpr {
+	var args []ast.Expr
+	if !p.check(token.RIGHT_PAREN) {
+		for {
+			if len(args) >= maxArgs {
+				p.errors = append(p.errors, &Error{fmt.Sprintf("Can't have more than %d arguments", maxArgs), p.peek()})
+			}
+			args = append(args, p.expression())
+
+			if !p.match(token.COMMA) {
+				break
+			}
+		}
+	}
+	paren := p.expect(token.RIGHT_PAREN, "Expect ')' after arguments")
+
+	return &ast.CallExpr{Callee: callee, Paren: paren, Args: args}
 }
 
 func (p *parser) primary() ast.Expr {
@@ -215,6 +523,17 @@ func (p *parser) primary() ast.Expr {
 		return &ast.LiteralExpr{Value: p.previous()}
 	}
 
+	if p.match(token.SUPER) {
+		keyword := p.previous()
+		p.expect(token.DOT, "Expect '.' after 'super'")
+		method := p.expect(token.IDENTIFIER, "Expect superclass method name")
+		return &ast.SuperExpr{Keyword: keyword, Method: method}
+	}
+
+	if p.match(token.THIS) {
+		return &ast.ThisExpr{Keyword: p.previous()}
+	}
+
 	if p.match(token.IDENTIFIER) {
 		return &ast.VariableExpr{Name: p.previous()}
 	}
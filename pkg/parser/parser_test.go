@@ -8,6 +8,35 @@ import (
 	"github.com/kevhlee/glox/pkg/parser"
 )
 
+// TestParseClass checks that the parser can correctly parse class
+// declarations and property expressions.
+func TestParseClass(t *testing.T) {
+	testParse(t, "class A { init(x) { this.x = x; } } A(1).x;", `CLASS(A)
+└── FUN(init(x))
+    └── EXPRESSION
+        └── SET(x)
+            ├── THIS
+            └── VARIABLE(x)
+EXPRESSION
+└── GET(x)
+    └── CALL
+        ├── VARIABLE(A)
+        └── NUMBER(1)
+`)
+}
+
+// TestParseSubclass checks that the parser can correctly parse subclass
+// declarations and 'super' expressions.
+func TestParseSubclass(t *testing.T) {
+	testParse(t, "class B < A { f() { super.f(); } }", `CLASS(B)
+├── VARIABLE(A)
+└── FUN(f())
+    └── EXPRESSION
+        └── CALL
+            └── SUPER(f)
+`)
+}
+
 // TestParseExpr checks that the parser can correctly parse expressions.
 func TestParseExpr(t *testing.T) {
 	res, err := parser.ParseSource("(5 - (3 - 1)) + -1;")
@@ -38,3 +67,161 @@ func TestParseExpr(t *testing.T) {
 		t.Errorf("\nExpected:\n%s\nActual:\n%s\n", expected, actual)
 	}
 }
+
+// TestParseFunction checks that the parser can correctly parse function
+// declarations, calls and return statements.
+func TestParseFunction(t *testing.T) {
+	testParse(t, "fun add(a, b) { return a + b; } add(1, 2)();", `FUN(add(a, b))
+└── RETURN
+    └── BINARY(+)
+        ├── VARIABLE(a)
+        └── VARIABLE(b)
+EXPRESSION
+└── CALL
+    └── CALL
+        ├── VARIABLE(add)
+        ├── NUMBER(1)
+        └── NUMBER(2)
+`)
+}
+
+// TestParseIf checks that the parser can correctly parse if statements and
+// binds a dangling else to the nearest if.
+func TestParseIf(t *testing.T) {
+	testParse(t, "if (a) if (b) print 1; else print 2;", `IF
+├── VARIABLE(a)
+└── IF
+    ├── VARIABLE(b)
+    ├── PRINT
+    │   └── NUMBER(1)
+    └── PRINT
+        └── NUMBER(2)
+`)
+}
+
+// TestParseFor checks that the parser can correctly desugar for loops into
+// while loops.
+func TestParseFor(t *testing.T) {
+	testParse(t, "for (var i = 0; i < 3; i = i + 1) print i; for (;;) {}", `BLOCK
+├── VAR(i)
+│   └── NUMBER(0)
+└── WHILE
+    ├── BINARY(<)
+    │   ├── VARIABLE(i)
+    │   └── NUMBER(3)
+    └── BLOCK
+        ├── PRINT
+        │   └── VARIABLE(i)
+        └── EXPRESSION
+            └── ASSIGN(i)
+                └── BINARY(+)
+                    ├── VARIABLE(i)
+                    └── NUMBER(1)
+WHILE
+├── TRUE
+└── BLOCK
+`)
+}
+
+// TestParseLogical checks that the parser gives 'and' a higher precedence
+// than 'or', and both a lower precedence than equality.
+func TestParseLogical(t *testing.T) {
+	testParse(t, "a or b and c == d;", `EXPRESSION
+└── LOGICAL(or)
+    ├── VARIABLE(a)
+    └── LOGICAL(and)
+        ├── VARIABLE(b)
+        └── BINARY(==)
+            ├── VARIABLE(c)
+            └── VARIABLE(d)
+`)
+}
+
+// TestParseTooManyArgs checks that the parser reports calls with more than
+// 255 arguments.
+func TestParseTooManyArgs(t *testing.T) {
+	args := strings.Repeat("1, ", 255) + "1"
+
+	_, err := parser.ParseSource("f(" + args + ");")
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if msg := err.Error(); msg != "Can't have more than 255 arguments" {
+		t.Errorf("Unexpected error message '%s'", msg)
+	}
+}
+
+// TestParseInteractive checks that the parser can parse interactive input as
+// either a single expression or a program.
+func TestParseInteractive(t *testing.T) {
+	body, expr, err := parser.ParseInteractive("a = 1 + 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != nil {
+		t.Errorf("Expected no statements, got %d instead", len(body))
+	}
+	if actual, expected := ast.Print(expr), "ASSIGN(a)\n└── BINARY(+)\n    ├── NUMBER(1)\n    └── NUMBER(2)\n"; actual != expected {
+		t.Errorf("\nExpected:\n%s\nActual:\n%s\n", expected, actual)
+	}
+
+	if _, _, err := parser.ParseInteractive("var a = 1; a"); err == nil {
+		t.Error("Expected an error for a program ending with a bare expression")
+	}
+
+	body, expr, err = parser.ParseInteractive("print 1; print 2;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expr != nil {
+		t.Errorf("Expected no expression, got '%s' instead", ast.Print(expr))
+	}
+	if len(body) != 2 {
+		t.Errorf("Expected 2 statements, got %d instead", len(body))
+	}
+}
+
+// TestIsIncomplete checks that the parser can tell incomplete source code apart
+// from complete or malformed source code.
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		source     string
+		incomplete bool
+	}{
+		{"print 1;", false},
+		{"{", true},
+		{"fun f() {\n  print 1;", true},
+		{"print (1 +", true},
+		{"print \"abc", true},
+		{"print 1", true},
+		{"1 + 2", false},
+		{"1 +", true},
+		{"print );", false},
+		{"{ print ); ", false},
+		{"@ {", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if actual := parser.IsIncomplete(test.source); actual != test.incomplete {
+			t.Errorf("Expected IsIncomplete(%q) to be %t", test.source, test.incomplete)
+		}
+	}
+}
+
+func testParse(t *testing.T, source, expected string) {
+	res, err := parser.ParseSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sb strings.Builder
+
+	for _, stmt := range res {
+		sb.WriteString(ast.Print(stmt))
+	}
+
+	if actual := sb.String(); actual != expected {
+		t.Errorf("\nExpected:\n%s\nActual:\n%s\n", expected, actual)
+	}
+}
@@ -0,0 +1,20 @@
+// Package resolver implements a static analysis pass that binds Lox variables
+// to the scopes they were declared in.
+package resolver
+
+import "github.com/kevhlee/glox/pkg/ast"
+
+// Resolve binds the variables in an AST to their declaring scopes.
+//
+// The depth of each resolved variable is recorded on its AST node. Any
+// semantic errors detected along the way are returned as a
+// [parser.ErrorList].
+func Resolve(body []ast.Stmt) error {
+	var r resolver
+
+	for _, stmt := range body {
+		ast.Walk(&r, stmt)
+	}
+
+	return r.errors.Err()
+}
@@ -0,0 +1,214 @@
+package resolver
+
+import (
+	"github.com/kevhlee/glox/internal/stack"
+	"github.com/kevhlee/glox/pkg/ast"
+	"github.com/kevhlee/glox/pkg/parser"
+	"github.com/kevhlee/glox/pkg/token"
+)
+
+// The kind of function body being resolved.
+type functionType int
+
+const (
+	functionNone functionType = iota
+	functionFunction
+	functionInitializer
+	functionMethod
+)
+
+// The kind of class body being resolved.
+type classType int
+
+const (
+	classNone classType = iota
+	classClass
+	classSubclass
+)
+
+// Contains the internal state and logic of the resolver.
+type resolver struct {
+	// Each scope maps a variable name to whether its initializer has been
+	// resolved
+	scopes   stack.Stack[map[string]bool]
+	function functionType
+	class    classType
+	errors   parser.ErrorList
+}
+
+func (r *resolver) error(tok *token.Token, msg string) {
+	r.errors = append(r.errors, &parser.Error{Msg: msg, Token: tok})
+}
+
+func (r *resolver) beginScope() {
+	r.scopes.Push(make(map[string]bool))
+}
+
+func (r *resolver) endScope() {
+	r.scopes.Pop()
+}
+
+func (r *resolver) declare(name *token.Token) {
+	scope, ok := r.scopes.Peek()
+	if !ok {
+		return
+	}
+
+	if _, ok := scope[name.Lexeme]; ok {
+		r.error(name, "Already a variable with this name in this scope")
+	}
+	scope[name.Lexeme] = false
+}
+
+func (r *resolver) define(name *token.Token) {
+	if scope, ok := r.scopes.Peek(); ok {
+		scope[name.Lexeme] = true
+	}
+}
+
+func (r *resolver) resolveLocal(name *token.Token) int {
+	for i, scope := range r.scopes.IterTop() {
+		if _, ok := scope[name.Lexeme]; ok {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *resolver) resolveFunction(stmt *ast.FunctionStmt, function functionType) {
+	enclosing := r.function
+
+	defer func() {
+		r.function = enclosing
+	}()
+
+	r.function = function
+
+	r.beginScope()
+	for _, param := range stmt.Params {
+		r.declare(param)
+		r.define(param)
+	}
+	for _, b := range stmt.Body {
+		ast.Walk(r, b)
+	}
+	r.endScope()
+}
+
+func (r *resolver) resolveClass(stmt *ast.ClassStmt) {
+	enclosing := r.class
+
+	defer func() {
+		r.class = enclosing
+	}()
+
+	r.class = classClass
+
+	r.declare(stmt.Name)
+	r.define(stmt.Name)
+
+	if stmt.Superclass != nil {
+		if stmt.Superclass.Name.Lexeme == stmt.Name.Lexeme {
+			r.error(stmt.Superclass.Name, "A class can't inherit from itself")
+		}
+
+		r.class = classSubclass
+		ast.Walk(r, stmt.Superclass)
+
+		r.beginScope()
+		if scope, ok := r.scopes.Peek(); ok {
+			scope["super"] = true
+		}
+	}
+
+	r.beginScope()
+	if scope, ok := r.scopes.Peek(); ok {
+		scope["this"] = true
+	}
+
+	for _, method := range stmt.Methods {
+		function := functionMethod
+		if method.Name.Lexeme == "init" {
+			function = functionInitializer
+		}
+		r.resolveFunction(method, function)
+	}
+
+	r.endScope()
+
+	if stmt.Superclass != nil {
+		r.endScope()
+	}
+}
+
+// Visit implements the [ast.Visitor] interface.
+func (r *resolver) Visit(node ast.Node) bool {
+	switch node := node.(type) {
+	// Stmt
+
+	case *ast.BlockStmt:
+		r.beginScope()
+		for _, b := range node.Body {
+			ast.Walk(r, b)
+		}
+		r.endScope()
+		return false
+
+	case *ast.ClassStmt:
+		r.resolveClass(node)
+		return false
+
+	case *ast.FunctionStmt:
+		r.declare(node.Name)
+		r.define(node.Name)
+		r.resolveFunction(node, functionFunction)
+		return false
+
+	case *ast.ReturnStmt:
+		if r.function == functionNone {
+			r.error(node.Keyword, "Can't return from top-level code")
+		} else if r.function == functionInitializer && node.Value != nil {
+			r.error(node.Keyword, "Can't return a value from an initializer")
+		}
+
+	case *ast.VarStmt:
+		r.declare(node.Name)
+		if node.Value != nil {
+			ast.Walk(r, node.Value)
+		}
+		r.define(node.Name)
+		return false
+
+	// Expr
+
+	case *ast.AssignExpr:
+		ast.Walk(r, node.Value)
+		node.Depth = r.resolveLocal(node.Name)
+		return false
+
+	case *ast.SuperExpr:
+		switch r.class {
+		case classNone:
+			r.error(node.Keyword, "Can't use 'super' outside of a class")
+		case classClass:
+			r.error(node.Keyword, "Can't use 'super' in a class with no superclass")
+		}
+		node.Depth = r.resolveLocal(node.Keyword)
+
+	case *ast.ThisExpr:
+		if r.class == classNone {
+			r.error(node.Keyword, "Can't use 'this' outside of a class")
+		}
+		node.Depth = r.resolveLocal(node.Keyword)
+
+	case *ast.VariableExpr:
+		if scope, ok := r.scopes.Peek(); ok {
+			if defined, ok := scope[node.Name.Lexeme]; ok && !defined {
+				r.error(node.Name, "Can't read local variable in its own initializer")
+			}
+		}
+		node.Depth = r.resolveLocal(node.Name)
+	}
+
+	return true
+}
@@ -0,0 +1,68 @@
+package resolver_test
+
+import (
+	"testing"
+
+	"github.com/kevhlee/glox/pkg/ast"
+	"github.com/kevhlee/glox/pkg/parser"
+	"github.com/kevhlee/glox/pkg/resolver"
+)
+
+// TestResolveDepth checks that the resolver records the correct scope depth
+// for each variable.
+func TestResolveDepth(t *testing.T) {
+	body := testResolve(t, "var a; { var b; { a; b; } }")
+
+	inner := body[1].(*ast.BlockStmt).Body[1].(*ast.BlockStmt).Body
+
+	if depth := inner[0].(*ast.ExpressionStmt).Expression.(*ast.VariableExpr).Depth; depth != -1 {
+		t.Errorf("Expected global variable to have depth -1, got %d instead", depth)
+	}
+	if depth := inner[1].(*ast.ExpressionStmt).Expression.(*ast.VariableExpr).Depth; depth != 1 {
+		t.Errorf("Expected local variable to have depth 1, got %d instead", depth)
+	}
+}
+
+// TestResolveErrors checks that the resolver reports semantic errors.
+func TestResolveErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		msg    string
+	}{
+		{"{ var a = a; }", "Can't read local variable in its own initializer"},
+		{"{ var a; var a; }", "Already a variable with this name in this scope"},
+		{"fun f(a, a) {}", "Already a variable with this name in this scope"},
+		{"return;", "Can't return from top-level code"},
+		{"print this;", "Can't use 'this' outside of a class"},
+		{"fun f() { return this; }", "Can't use 'this' outside of a class"},
+		{"class A { init() { return 1; } }", "Can't return a value from an initializer"},
+		{"class A < A {}", "A class can't inherit from itself"},
+		{"super.f();", "Can't use 'super' outside of a class"},
+		{"class A { f() { super.f(); } }", "Can't use 'super' in a class with no superclass"},
+	}
+
+	for _, test := range tests {
+		body, err := parser.ParseSource(test.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = resolver.Resolve(body)
+		if err == nil {
+			t.Errorf("Expected error '%s' for '%s'", test.msg, test.source)
+		} else if msg := err.Error(); msg != test.msg {
+			t.Errorf("Expected error '%s' for '%s', got '%s' instead", test.msg, test.source, msg)
+		}
+	}
+}
+
+func testResolve(t *testing.T, source string) []ast.Stmt {
+	body, err := parser.ParseSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resolver.Resolve(body); err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
@@ -8,6 +8,7 @@ import "github.com/kevhlee/glox/pkg/token"
 func ScanSource(source string) []*token.Token {
 	var s scanner
 
+	s.text = source
 	s.source = []rune(source)
 	s.line = 1
 
@@ -1,14 +1,30 @@
 package scanner
 
-import "github.com/kevhlee/glox/pkg/token"
+import (
+	"unicode/utf8"
+
+	"github.com/kevhlee/glox/pkg/token"
+)
 
 // Contains the internal state and logic of the Lox scanner.
 type scanner struct {
+	text    string
 	source  []rune
 	tokens  []*token.Token
 	start   int
 	current int
 	line    int
+
+	// The byte offsets in text corresponding to start and current
+	startOffset   int
+	currentOffset int
+
+	// The position of the current token's first character
+	startLine   int
+	startColumn int
+
+	// The index of the first character of the current line
+	lineStart int
 }
 
 func (s *scanner) isScanning() bool {
@@ -16,8 +32,28 @@ func (s *scanner) isScanning() bool {
 }
 
 func (s *scanner) advance() rune {
+	ch := s.source[s.current]
 	s.current++
-	return s.source[s.current-1]
+
+	// Invalid UTF-8 is decoded as U+FFFD, which is longer than the byte it
+	// replaces
+	_, size := utf8.DecodeRuneInString(s.text[s.currentOffset:])
+	s.currentOffset += size
+	return ch
+}
+
+// Starts a new line after a newline character was consumed.
+func (s *scanner) newline() {
+	s.line++
+	s.lineStart = s.current
+}
+
+// Marks the current position as the start of the next token.
+func (s *scanner) begin() {
+	s.start = s.current
+	s.startOffset = s.currentOffset
+	s.startLine = s.line
+	s.startColumn = s.current - s.lineStart + 1
 }
 
 func (s *scanner) peek() rune {
@@ -46,7 +82,10 @@ func (s *scanner) error(msg string) {
 	s.tokens = append(s.tokens, &token.Token{
 		Type:   token.ERROR,
 		Lexeme: msg,
-		Line:   s.line,
+		Line:   s.startLine,
+		Column: s.startColumn,
+		Start:  s.startOffset,
+		End:    s.currentOffset,
 	})
 }
 
@@ -54,7 +93,10 @@ func (s *scanner) addToken(t token.Type) {
 	s.tokens = append(s.tokens, &token.Token{
 		Type:   t,
 		Lexeme: string(s.source[s.start:s.current]),
-		Line:   s.line,
+		Line:   s.startLine,
+		Column: s.startColumn,
+		Start:  s.startOffset,
+		End:    s.currentOffset,
 	})
 }
 
@@ -65,7 +107,7 @@ func (s *scanner) scan() []*token.Token {
 	}
 
 	for s.isScanning() {
-		s.start = s.current
+		s.begin()
 
 		switch ch := s.advance(); ch {
 		case '(':
@@ -123,7 +165,7 @@ func (s *scanner) scan() []*token.Token {
 		case ' ', '\r', '\t':
 			continue
 		case '\n':
-			s.line++
+			s.newline()
 		case '"':
 			s.scanString()
 		default:
@@ -137,20 +179,17 @@ func (s *scanner) scan() []*token.Token {
 		}
 	}
 
-	s.tokens = append(s.tokens, &token.Token{
-		Type: token.EOF,
-		Line: s.line,
-	})
+	s.begin()
+	s.addToken(token.EOF)
 
 	return s.tokens
 }
 
 func (s *scanner) scanString() {
 	for s.isScanning() && s.peek() != '"' {
-		if s.peek() == '\n' {
-			s.line++
+		if s.advance() == '\n' {
+			s.newline()
 		}
-		s.advance()
 	}
 
 	if !s.isScanning() {
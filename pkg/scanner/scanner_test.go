@@ -70,6 +70,55 @@ func TestScanNumbers(t *testing.T) {
 	})
 }
 
+// TestScanPositions checks to make sure the scanner tracks the line, column
+// and byte offsets of each token, including across multi-line strings and
+// multi-byte characters.
+func TestScanPositions(t *testing.T) {
+	source := `var s = "a
+bc"; print "é" + s;
+  @`
+
+	expected := []token.Token{
+		{Type: token.VAR, Lexeme: "var", Line: 1, Column: 1, Start: 0, End: 3},
+		{Type: token.IDENTIFIER, Lexeme: "s", Line: 1, Column: 5, Start: 4, End: 5},
+		{Type: token.EQUAL, Lexeme: "=", Line: 1, Column: 7, Start: 6, End: 7},
+		{Type: token.STRING, Lexeme: "\"a\nbc\"", Line: 1, Column: 9, Start: 8, End: 14},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 2, Column: 4, Start: 14, End: 15},
+		{Type: token.PRINT, Lexeme: "print", Line: 2, Column: 6, Start: 16, End: 21},
+		{Type: token.STRING, Lexeme: `"é"`, Line: 2, Column: 12, Start: 22, End: 26},
+		{Type: token.PLUS, Lexeme: "+", Line: 2, Column: 16, Start: 27, End: 28},
+		{Type: token.IDENTIFIER, Lexeme: "s", Line: 2, Column: 18, Start: 29, End: 30},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 2, Column: 19, Start: 30, End: 31},
+		{Type: token.ERROR, Lexeme: "Unexpected character", Line: 3, Column: 3, Start: 34, End: 35},
+		{Type: token.EOF, Lexeme: "", Line: 3, Column: 4, Start: 35, End: 35},
+	}
+
+	testScan(t, source, expected)
+
+	actual := scanner.ScanSource(source)
+
+	for i := range expected {
+		if actual[i].Column != expected[i].Column {
+			t.Errorf("Expected column '%d', got '%d' instead", expected[i].Column, actual[i].Column)
+		}
+		if actual[i].Start != expected[i].Start || actual[i].End != expected[i].End {
+			t.Errorf("Expected offsets '%d:%d', got '%d:%d' instead", expected[i].Start, expected[i].End, actual[i].Start, actual[i].End)
+		}
+	}
+}
+
+// TestScanInvalidUTF8 checks to make sure the byte offsets of tokens stay
+// aligned with the source code after invalid UTF-8.
+func TestScanInvalidUTF8(t *testing.T) {
+	testScan(t, "\xff\xfe x;", []token.Token{
+		{Type: token.ERROR, Lexeme: "Unexpected character", Line: 1},
+		{Type: token.ERROR, Lexeme: "Unexpected character", Line: 1},
+		{Type: token.IDENTIFIER, Lexeme: "x", Line: 1},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 1},
+		{Type: token.EOF, Lexeme: "", Line: 1},
+	})
+}
+
 // TestScanPunctuators checks to make sure the scanner handles punctuation
 // characters.
 func TestScanPunctuators(t *testing.T) {
@@ -147,5 +196,8 @@ func testScan(t *testing.T, source string, expected []token.Token) {
 		if actual[i].Line != expected[i].Line {
 			t.Errorf("Expected line '%d', got '%d' instead", expected[i].Line, actual[i].Line)
 		}
+		if actual[i].Type != token.ERROR && source[actual[i].Start:actual[i].End] != actual[i].Lexeme {
+			t.Errorf("Expected offsets of '%s' to match its lexeme, got '%s' instead", actual[i].Lexeme, source[actual[i].Start:actual[i].End])
+		}
 	}
 }
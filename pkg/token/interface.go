@@ -2,6 +2,11 @@
 // language.
 package token
 
+import (
+	"maps"
+	"slices"
+)
+
 // IsDigit checks if a given character is a digit in a Lox number literal.
 func IsDigit(ch rune) bool {
 	return ch >= '0' && ch <= '9'
@@ -29,3 +34,8 @@ func ParseKeyword(s string) (Type, bool) {
 	t, ok := keywords[s]
 	return t, ok
 }
+
+// Keywords returns the Lox keywords in alphabetical order.
+func Keywords() []string {
+	return slices.Sorted(maps.Keys(keywords))
+}
@@ -143,10 +143,19 @@ var (
 type Token struct {
 	Type
 	Lexeme string
+
+	// The line and column (both starting from 1) of the token's first
+	// character. Columns are counted in characters rather than bytes.
 	Line   int
+	Column int
+
+	// The byte offsets of the token in the source code, such that
+	// source[Start:End] is the token's text.
+	Start int
+	End   int
 }
 
 // String implements the [fmt.Stringer] interface.
 func (t *Token) String() string {
-	return fmt.Sprintf("<%s %s %d>", t.Type, t.Lexeme, t.Line)
+	return fmt.Sprintf("<%s %s %d:%d>", t.Type, t.Lexeme, t.Line, t.Column)
 }
@@ -0,0 +1,53 @@
+// Package vm implements a stack-based virtual machine that executes Lox
+// bytecode.
+package vm
+
+import (
+	"context"
+
+	"github.com/kevhlee/glox/pkg/ast"
+	"github.com/kevhlee/glox/pkg/compiler"
+	"github.com/kevhlee/glox/pkg/lox"
+)
+
+// Backend is a [lox.Backend] that compiles programs into bytecode and executes
+// them on the virtual machine.
+//
+// Programs produce the same output and errors as with the default backend.
+// Steps are counted per instruction rather than per AST node, and the maximum
+// depth of nested scopes is not enforced, since local variables live on the
+// stack of the virtual machine.
+type Backend struct{}
+
+// Execute implements the [lox.Backend] interface.
+func (Backend) Execute(ctx context.Context, config *lox.Config, body []ast.Stmt) error {
+	script, err := compiler.Compile(body)
+	if err != nil {
+		return err
+	}
+	return Run(ctx, config, script)
+}
+
+// Run executes a compiled script with the settings of a [lox.Config].
+//
+// Runtime errors are returned as a [*lox.Error].
+func Run(ctx context.Context, config *lox.Config, script *compiler.Function) (err error) {
+	m := machine{config: config, budget: lox.NewBudget(ctx, config)}
+
+	defer func() {
+		if r := recover(); r != nil {
+			if r, ok := r.(*lox.Error); ok {
+				err = r
+			} else {
+				panic(r)
+			}
+		}
+	}()
+
+	closure := &Closure{fn: script}
+	m.push(lox.ObjectValue(closure))
+	m.frames = append(m.frames, frame{closure: closure, name: "script"})
+	m.run()
+
+	return nil
+}
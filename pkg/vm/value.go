@@ -0,0 +1,101 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/kevhlee/glox/pkg/compiler"
+	"github.com/kevhlee/glox/pkg/lox"
+)
+
+// Closure is a compiled Lox function with the variables it captured.
+type Closure struct {
+	fn       *compiler.Function
+	upvalues []*upvalue
+}
+
+// Name returns the name of the function.
+func (c *Closure) Name() string {
+	return c.fn.Name
+}
+
+// String implements the [fmt.Stringer] interface.
+func (c *Closure) String() string {
+	return c.fn.String()
+}
+
+// TypeName implements the [lox.Object] interface.
+func (c *Closure) TypeName() string {
+	return "function"
+}
+
+// A variable captured by a closure.
+//
+// While the variable is still on the stack, the upvalue is open and refers to
+// its stack slot. Once the variable goes out of scope, the upvalue is closed
+// and holds its value.
+type upvalue struct {
+	open   bool
+	slot   int
+	closed lox.Value
+}
+
+// BoundMethod is a method bound to an instance.
+type BoundMethod struct {
+	receiver *Instance
+	method   *Closure
+}
+
+// String implements the [fmt.Stringer] interface.
+func (b *BoundMethod) String() string {
+	return b.method.String()
+}
+
+// TypeName implements the [lox.Object] interface.
+func (b *BoundMethod) TypeName() string {
+	return "function"
+}
+
+// Class is a Lox class.
+//
+// Methods are copied from the superclass when the class is created, so they
+// can be looked up directly.
+type Class struct {
+	name    string
+	methods map[string]*Closure
+}
+
+// Name returns the name of the class.
+func (c *Class) Name() string {
+	return c.name
+}
+
+// String implements the [fmt.Stringer] interface.
+func (c *Class) String() string {
+	return c.name
+}
+
+// TypeName implements the [lox.Object] interface.
+func (c *Class) TypeName() string {
+	return "class"
+}
+
+// Instance is an instance of a Lox class.
+type Instance struct {
+	class  *Class
+	fields map[string]lox.Value
+}
+
+// Class returns the class of the instance.
+func (in *Instance) Class() *Class {
+	return in.class
+}
+
+// String implements the [fmt.Stringer] interface.
+func (in *Instance) String() string {
+	return fmt.Sprintf("%s instance", in.class.name)
+}
+
+// TypeName implements the [lox.Object] interface.
+func (in *Instance) TypeName() string {
+	return "instance"
+}
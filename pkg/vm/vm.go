@@ -0,0 +1,451 @@
+package vm
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/kevhlee/glox/pkg/compiler"
+	"github.com/kevhlee/glox/pkg/lox"
+)
+
+// Contains the internal state and logic of the virtual machine.
+type machine struct {
+	config *lox.Config
+
+	// Counts steps and allocations against the limits of the run, like in
+	// the tree-walking interpreter
+	budget *lox.Budget
+
+	stack  []lox.Value
+	frames []frame
+
+	// The open upvalues, sorted by stack slot
+	openUpvalues []*upvalue
+}
+
+// A call currently being executed by the virtual machine.
+type frame struct {
+	closure *Closure
+
+	// The offset of the next instruction and of the instruction being executed
+	ip    int
+	start int
+
+	// The stack slot holding the callee, followed by the arguments and locals
+	base int
+
+	// The name reported in stack traces
+	name string
+}
+
+func (m *machine) push(value lox.Value) {
+	m.stack = append(m.stack, value)
+}
+
+func (m *machine) pop() lox.Value {
+	value := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+	return value
+}
+
+func (m *machine) peek(distance int) lox.Value {
+	return m.stack[len(m.stack)-1-distance]
+}
+
+func (f *frame) readByte() int {
+	b := f.closure.fn.Chunk.Code[f.ip]
+	f.ip++
+	return int(b)
+}
+
+func (f *frame) readShort() int {
+	operand := f.closure.fn.Chunk.ReadShort(f.ip)
+	f.ip += 2
+	return operand
+}
+
+func (f *frame) readString() string {
+	return f.closure.fn.Chunk.Constants[f.readShort()].AsString()
+}
+
+// Aborts execution with an error at the instruction being executed.
+func (m *machine) fail(kind lox.ErrorKind, msg string) {
+	f := &m.frames[len(m.frames)-1]
+	chunk := &f.closure.fn.Chunk
+
+	panic(&lox.Error{
+		Msg:   msg,
+		Line:  chunk.Lines[f.start],
+		Kind:  kind,
+		Token: chunk.Token(f.start),
+		Trace: m.trace(),
+	})
+}
+
+// Builds a stack trace of the calls being executed.
+func (m *machine) trace() []lox.Frame {
+	frames := make([]lox.Frame, len(m.frames))
+	for i, f := range m.frames {
+		frames[i] = lox.Frame{Function: f.name, Line: f.closure.fn.Chunk.Lines[f.start]}
+	}
+	return frames
+}
+
+// Counts an execution step, aborting execution if the step budget was exceeded
+// or the context was cancelled.
+func (m *machine) step() {
+	if err := m.budget.Step(); err != nil {
+		m.fail(err.Kind, err.Msg)
+	}
+}
+
+// Counts an approximate number of bytes allocated for a Lox value, aborting
+// execution if the allocation limit was exceeded.
+func (m *machine) allocate(size int) {
+	if err := m.budget.Allocate(size); err != nil {
+		m.fail(err.Kind, err.Msg)
+	}
+}
+
+func (m *machine) run() {
+	for {
+		f := &m.frames[len(m.frames)-1]
+		chunk := &f.closure.fn.Chunk
+
+		f.start = f.ip
+		m.step()
+
+		switch op := compiler.OpCode(f.readByte()); op {
+		case compiler.OpConstant:
+			m.push(chunk.Constants[f.readShort()])
+		case compiler.OpNil:
+			m.push(lox.Value{})
+		case compiler.OpTrue:
+			m.push(lox.BoolValue(true))
+		case compiler.OpFalse:
+			m.push(lox.BoolValue(false))
+		case compiler.OpPop:
+			m.pop()
+
+		case compiler.OpGetLocal:
+			m.push(m.stack[f.base+f.readByte()])
+		case compiler.OpSetLocal:
+			m.stack[f.base+f.readByte()] = m.peek(0)
+
+		case compiler.OpGetGlobal:
+			name := f.readString()
+			value, ok := m.config.Globals.Get(name)
+			if !ok {
+				m.fail(lox.KindRuntime, fmt.Sprintf("Undefined variable '%s'", name))
+			}
+			m.push(value)
+		case compiler.OpDefineGlobal:
+			m.config.Globals.Define(f.readString(), m.pop())
+		case compiler.OpSetGlobal:
+			name := f.readString()
+			if !m.config.Globals.Assign(name, m.peek(0)) {
+				m.fail(lox.KindRuntime, fmt.Sprintf("Undefined variable '%s'", name))
+			}
+
+		case compiler.OpGetUpvalue:
+			if uv := f.closure.upvalues[f.readByte()]; uv.open {
+				m.push(m.stack[uv.slot])
+			} else {
+				m.push(uv.closed)
+			}
+		case compiler.OpSetUpvalue:
+			if uv := f.closure.upvalues[f.readByte()]; uv.open {
+				m.stack[uv.slot] = m.peek(0)
+			} else {
+				uv.closed = m.peek(0)
+			}
+
+		case compiler.OpGetProperty:
+			instance, ok := m.peek(0).AsObject().(*Instance)
+			if !ok {
+				m.fail(lox.KindRuntime, "Only instances have properties")
+			}
+
+			name := f.readString()
+			if value, ok := instance.fields[name]; ok {
+				m.stack[len(m.stack)-1] = value
+			} else {
+				m.stack[len(m.stack)-1] = lox.ObjectValue(m.bindMethod(instance.class, instance, name))
+			}
+		case compiler.OpSetProperty:
+			instance, ok := m.peek(1).AsObject().(*Instance)
+			if !ok {
+				m.fail(lox.KindRuntime, "Only instances have fields")
+			}
+
+			value := m.pop()
+			instance.fields[f.readString()] = value
+			m.stack[len(m.stack)-1] = value
+		case compiler.OpGetSuper:
+			superclass := expect[*Class](m, m.pop())
+			instance := expect[*Instance](m, m.pop())
+			m.push(lox.ObjectValue(m.bindMethod(superclass, instance, f.readString())))
+
+		case compiler.OpEqual:
+			r, l := m.pop(), m.pop()
+			m.push(lox.BoolValue(l.Equal(r)))
+		case compiler.OpGreater, compiler.OpGreaterEqual, compiler.OpLess, compiler.OpLessEqual,
+			compiler.OpSubtract, compiler.OpMultiply, compiler.OpDivide:
+			m.binaryOp(op)
+		case compiler.OpAdd:
+			r, l := m.pop(), m.pop()
+			if l.Type() == lox.TypeNumber && r.Type() == lox.TypeNumber {
+				m.push(lox.NumberValue(l.AsNumber() + r.AsNumber()))
+				break
+			}
+			if l.Type() == lox.TypeString && r.Type() == lox.TypeString {
+				lhs, rhs := l.AsString(), r.AsString()
+				m.allocate(len(lhs) + len(rhs))
+				m.push(lox.StringValue(lhs + rhs))
+				break
+			}
+			m.fail(lox.KindRuntime, "Operands must be two numbers or two strings")
+		case compiler.OpNot:
+			m.push(lox.BoolValue(!m.pop().Truthy()))
+		case compiler.OpNegate:
+			value := m.peek(0)
+			if value.Type() != lox.TypeNumber {
+				m.fail(lox.KindRuntime, "Operand must be a number")
+			}
+			m.stack[len(m.stack)-1] = lox.NumberValue(-value.AsNumber())
+
+		case compiler.OpPrint:
+			fmt.Fprintln(m.config.Stdout, m.pop().String())
+
+		case compiler.OpJump:
+			offset := f.readShort()
+			f.ip += offset
+		case compiler.OpJumpIfFalse:
+			offset := f.readShort()
+			if !m.peek(0).Truthy() {
+				f.ip += offset
+			}
+		case compiler.OpLoop:
+			offset := f.readShort()
+			f.ip -= offset
+
+		case compiler.OpCall:
+			argc := f.readByte()
+			m.callValue(m.peek(argc), argc)
+
+		case compiler.OpClosure:
+			fn := chunk.Constants[f.readShort()].AsObject().(*compiler.Function)
+			m.allocate(lox.FunctionSize)
+
+			closure := &Closure{fn: fn, upvalues: make([]*upvalue, fn.UpvalueCount)}
+			for i := range closure.upvalues {
+				isLocal, index := f.readByte(), f.readByte()
+				if isLocal == 1 {
+					closure.upvalues[i] = m.captureUpvalue(f.base + index)
+				} else {
+					closure.upvalues[i] = f.closure.upvalues[index]
+				}
+			}
+			m.push(lox.ObjectValue(closure))
+		case compiler.OpCloseUpvalue:
+			m.closeUpvalues(len(m.stack) - 1)
+			m.pop()
+
+		case compiler.OpReturn:
+			result := m.pop()
+			m.closeUpvalues(f.base)
+
+			m.frames = m.frames[:len(m.frames)-1]
+			if len(m.frames) == 0 {
+				return
+			}
+
+			m.stack = m.stack[:f.base]
+			m.push(result)
+
+		case compiler.OpClass:
+			m.push(lox.ObjectValue(&Class{name: f.readString(), methods: make(map[string]*Closure)}))
+		case compiler.OpInherit:
+			superclass, ok := m.peek(1).AsObject().(*Class)
+			if !ok {
+				m.fail(lox.KindRuntime, "Superclass must be a class")
+			}
+
+			subclass := expect[*Class](m, m.pop())
+			for name, method := range superclass.methods {
+				subclass.methods[name] = method
+			}
+		case compiler.OpMethod:
+			method := expect[*Closure](m, m.pop())
+			class := expect[*Class](m, m.peek(0))
+			class.methods[f.readString()] = method
+
+		default:
+			m.fail(lox.KindRuntime, fmt.Sprintf("Unknown opcode %d", op))
+		}
+	}
+}
+
+// Returns the object an instruction expects the compiler to have put on the
+// stack, aborting execution if a malformed compiled file put something else
+// there.
+func expect[T lox.Object](m *machine, value lox.Value) T {
+	obj, ok := value.AsObject().(T)
+	if !ok {
+		m.fail(lox.KindRuntime, "Invalid bytecode")
+	}
+	return obj
+}
+
+// Executes an arithmetic or comparison instruction on two numbers.
+func (m *machine) binaryOp(op compiler.OpCode) {
+	r, l := m.pop(), m.pop()
+
+	if l.Type() != lox.TypeNumber || r.Type() != lox.TypeNumber {
+		m.fail(lox.KindRuntime, "Operands must be numbers")
+	}
+
+	lhs, rhs := l.AsNumber(), r.AsNumber()
+
+	switch op {
+	case compiler.OpGreater:
+		m.push(lox.BoolValue(lhs > rhs))
+	case compiler.OpGreaterEqual:
+		m.push(lox.BoolValue(lhs >= rhs))
+	case compiler.OpLess:
+		m.push(lox.BoolValue(lhs < rhs))
+	case compiler.OpLessEqual:
+		m.push(lox.BoolValue(lhs <= rhs))
+	case compiler.OpSubtract:
+		m.push(lox.NumberValue(lhs - rhs))
+	case compiler.OpMultiply:
+		m.push(lox.NumberValue(lhs * rhs))
+	case compiler.OpDivide:
+		m.push(lox.NumberValue(lhs / rhs))
+	}
+}
+
+// Calls the callee below the arguments on top of the stack.
+func (m *machine) callValue(callee lox.Value, argc int) {
+	var (
+		arity int
+		name  string
+	)
+
+	switch callee := callee.AsObject().(type) {
+	case *Closure:
+		arity, name = callee.fn.Arity, callee.fn.Name
+	case *BoundMethod:
+		arity, name = callee.method.fn.Arity, callee.method.fn.Name
+	case *Class:
+		if init, ok := callee.methods["init"]; ok {
+			arity = init.fn.Arity
+		}
+		name = callee.name
+	case *lox.NativeFunc:
+		arity, name = callee.Arity(), callee.Name()
+	default:
+		m.fail(lox.KindRuntime, "Can only call functions and classes")
+	}
+
+	if argc != arity {
+		m.fail(lox.KindRuntime, fmt.Sprintf("Expected %d arguments but got %d", arity, argc))
+	}
+
+	// The top-level script is not a call
+	if limit := m.config.MaxCallDepth; limit > 0 && len(m.frames)-1 >= limit {
+		m.fail(lox.KindStackOverflow, "Stack overflow")
+	}
+
+	base := len(m.stack) - argc - 1
+
+	switch callee := callee.AsObject().(type) {
+	case *Closure:
+		m.call(callee, base, name)
+	case *BoundMethod:
+		m.stack[base] = lox.ObjectValue(callee.receiver)
+		m.call(callee.method, base, name)
+	case *Class:
+		if err := m.budget.Allocate(lox.InstanceSize); err != nil {
+			m.failInCall(err, name)
+		}
+		m.stack[base] = lox.ObjectValue(&Instance{class: callee, fields: make(map[string]lox.Value)})
+
+		if init, ok := callee.methods["init"]; ok {
+			m.call(init, base, name)
+		}
+	case *lox.NativeFunc:
+		m.callNative(callee, base)
+	}
+}
+
+func (m *machine) call(closure *Closure, base int, name string) {
+	m.frames = append(m.frames, frame{closure: closure, base: base, name: name})
+}
+
+func (m *machine) callNative(fn *lox.NativeFunc, base int) {
+	value, err := fn.Call(slices.Clone(m.stack[base+1:]))
+	if err == nil {
+		m.stack = m.stack[:base]
+		m.push(value)
+		return
+	}
+
+	f := &m.frames[len(m.frames)-1]
+	chunk := &f.closure.fn.Chunk
+
+	m.failInCall(lox.NativeError(err, chunk.Lines[f.start], chunk.Token(f.start)), fn.Name())
+}
+
+// Aborts execution with an error raised while calling a class or native
+// function, which is reported at the call unless it has a line. Like
+// user-defined functions, the callee has its own frame in the stack trace.
+func (m *machine) failInCall(err *lox.Error, name string) {
+	if err.Line == 0 {
+		f := &m.frames[len(m.frames)-1]
+		chunk := &f.closure.fn.Chunk
+
+		err.Line = chunk.Lines[f.start]
+		err.Token = chunk.Token(f.start)
+	}
+
+	err.Trace = append(m.trace(), lox.Frame{Function: name, Line: err.Line})
+	panic(err)
+}
+
+// Binds a method of a class to an instance, aborting execution if the method
+// does not exist.
+func (m *machine) bindMethod(class *Class, instance *Instance, name string) *BoundMethod {
+	method, ok := class.methods[name]
+	if !ok {
+		m.fail(lox.KindRuntime, fmt.Sprintf("Undefined property '%s'", name))
+	}
+	return &BoundMethod{receiver: instance, method: method}
+}
+
+// Returns the open upvalue for a stack slot, creating it if needed.
+func (m *machine) captureUpvalue(slot int) *upvalue {
+	i, found := slices.BinarySearchFunc(m.openUpvalues, slot, func(uv *upvalue, slot int) int {
+		return uv.slot - slot
+	})
+	if found {
+		return m.openUpvalues[i]
+	}
+
+	uv := &upvalue{open: true, slot: slot}
+	m.openUpvalues = slices.Insert(m.openUpvalues, i, uv)
+	return uv
+}
+
+// Closes the open upvalues for stack slots at or above a given slot.
+func (m *machine) closeUpvalues(slot int) {
+	i := len(m.openUpvalues)
+	for i > 0 && m.openUpvalues[i-1].slot >= slot {
+		i--
+
+		uv := m.openUpvalues[i]
+		uv.closed = m.stack[uv.slot]
+		uv.open = false
+	}
+	m.openUpvalues = m.openUpvalues[:i]
+}
@@ -0,0 +1,238 @@
+package vm_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kevhlee/glox/pkg/compiler"
+	"github.com/kevhlee/glox/pkg/lox"
+	"github.com/kevhlee/glox/pkg/parser"
+	"github.com/kevhlee/glox/pkg/vm"
+)
+
+// TestBackendParity checks that programs produce the same output, errors and
+// exit status on the virtual machine as with the tree-walking interpreter.
+func TestBackendParity(t *testing.T) {
+	sources := []string{
+		// Expressions and control flow
+		`print 1 + 2 * 3 - 4 / 8; print -(1 + 1); print !nil; print "a" + "b";`,
+		`print 1 == 1; print 1 != 2; print "a" == "a"; print nil == false; print 0/0 == 0/0;`,
+		`print 1 < 2; print 2 <= 1; print 3 > 2; print 3 >= 4;`,
+		`if (nil) print "then"; else print "else"; print nil or "or"; print 1 and 2;`,
+		`for (var i = 0; i < 3; i = i + 1) print i; var j = 0; while (j < 2) j = j + 1; print j;`,
+		`var a = 1; { var a = 2; { var a = 3; print a; } print a; } print a;`,
+
+		// Functions and closures
+		`fun fib(n) { if (n < 2) return n; return fib(n - 1) + fib(n - 2); } print fib(15); print fib; print clock;`,
+		`fun makeCounter() { var i = 0; fun count() { i = i + 1; return i; } return count; }
+var a = makeCounter(); var b = makeCounter(); print a(); print a(); print b();`,
+		`var x = "global"; { fun show() { print x; } show(); var x = "local"; show(); }`,
+		`fun outer() { var x = 1; fun middle() { fun inner() { x = x + 1; return x; } return inner; } return middle(); }
+var f = outer(); print f(); print f();`,
+		`var fs = nil; for (var i = 0; i < 2; i = i + 1) { var j = i; fun g() { return j; } fs = g; } print fs();`,
+		`fun noReturn() {} print noReturn();`,
+
+		// Classes
+		`class A { init(x) { this.x = x; } get() { return this.x; } }
+var a = A(1); print a.get(); print a; print A; print a.get; a.x = 2; print a.get();
+print a.init(3).x; print A(4).init(5).x;`,
+		`class A { say() { print "A"; } } class B < A { say() { super.say(); print "B"; } }
+class C < B { say() { super.say(); print "C"; } } C().say();`,
+		`class A { method() { fun inner() { return this; } return inner; } } var a = A(); print a.method()() == a;`,
+		`class A { init() { this.f = this.g; } g() { return "g"; } } print A().f();`,
+		`class A { init() { return; print "unreachable"; } } print A();`,
+
+		// Runtime errors
+		`print undefinedVar;`,
+		`undefinedVar = 1;`,
+		`print 1 + nil;`,
+		`print 1 < "a";`,
+		`print -"a";`,
+		`var x = "a"; x.foo = 1;`,
+		`var x = 1; print x.foo;`,
+		`class A {} print A().missing;`,
+		`"x"();`,
+		`fun f(a) {} f(1, 2);`,
+		`class A {} A(1);`,
+		`var N = 1; class B < N {}`,
+		`class A {} class B < A { m() { return super.missing; } } B().m();`,
+		`fun a(n) { return b(n); }
+fun b(n) { return n + nil; }
+print a(1);`,
+		`class A { init() { this.y = -"s"; } }
+A();`,
+		`fun r(n) { return r(n + 1); }
+r(0);`,
+		`fun f() { return clock(1); }
+f();`,
+
+		// Compile errors
+		`print 1 +;`,
+		`return 1;`,
+	}
+
+	for _, source := range sources {
+		testParity(t, source)
+	}
+
+	// Allocations are counted the same way by both backends, so the same
+	// programs run out of memory
+	limited := []string{
+		`for (var i = 0; i < 100; i = i + 1) { class A { a() {} b() {} c() {} d() {} } }`,
+		`for (var i = 0; i < 100; i = i + 1) { fun f() {} fun g() {} }`,
+		`class A {} for (var i = 0; i < 100; i = i + 1) A();`,
+		`var s = ""; for (var i = 0; i < 100; i = i + 1) s = s + "0123456789";`,
+	}
+
+	for _, limit := range []int{1000, 100000} {
+		for _, source := range limited {
+			testParity(t, source, lox.WithAllocationLimit(limit))
+		}
+	}
+}
+
+// TestRunLimits checks that the virtual machine enforces the limits of its
+// interpreter.
+func TestRunLimits(t *testing.T) {
+	tests := []struct {
+		source string
+		opts   []lox.Option
+		kind   lox.ErrorKind
+	}{
+		{"while (true) {}", []lox.Option{lox.WithStepLimit(1000)}, lox.KindBudgetExceeded},
+		{"fun f() { f(); } f();", []lox.Option{lox.WithMaxCallDepth(100)}, lox.KindStackOverflow},
+		{`var s = "x"; while (true) s = s + s;`, []lox.Option{lox.WithAllocationLimit(1 << 20)}, lox.KindOutOfMemory},
+	}
+
+	for _, test := range tests {
+		var stderr strings.Builder
+
+		opts := append([]lox.Option{lox.WithBackend(vm.Backend{}), lox.WithStderr(&stderr)}, test.opts...)
+		in := lox.NewInterpreter(opts...)
+
+		err, ok := in.Run(test.source).(*lox.Error)
+		if !ok || err.Kind != test.kind {
+			t.Errorf("Expected error of kind %d for %q but got %v", test.kind, test.source, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	in := lox.NewInterpreter(lox.WithBackend(vm.Backend{}), lox.WithStderr(&strings.Builder{}))
+	if err, ok := in.RunContext(ctx, "while (true) {}").(*lox.Error); !ok || err.Kind != lox.KindCancelled {
+		t.Errorf("Expected cancelled error but got %v", err)
+	}
+}
+
+// TestRunGlobals checks that global bindings persist across runs, like in a
+// REPL.
+func TestRunGlobals(t *testing.T) {
+	var stdout strings.Builder
+
+	in := lox.NewInterpreter(lox.WithBackend(vm.Backend{}), lox.WithStdout(&stdout))
+
+	for _, source := range []string{
+		"var a = 1;",
+		"fun inc() { a = a + 1; return a; }",
+		"print inc();",
+		"print inc();",
+	} {
+		if err := in.Run(source); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if expected := "2\n3\n"; stdout.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, stdout.String())
+	}
+}
+
+// TestRunCompiled checks that a script runs the same after being encoded and
+// decoded, with errors reported at the same lines.
+func TestRunCompiled(t *testing.T) {
+	body, err := parser.ParseSource("fun f(x) {\n  print x;\n  return x + nil;\n}\nf(1);")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	script, err := compiler.Compile(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	script, err = compiler.Decode(compiler.Encode(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout strings.Builder
+
+	in := lox.NewInterpreter(lox.WithStdout(&stdout))
+	err = vm.Run(context.Background(), in.Config(), script)
+
+	if expected := "1\n"; stdout.String() != expected {
+		t.Errorf("Expected output %q but got %q", expected, stdout.String())
+	}
+
+	expectedTrace := []lox.Frame{{Function: "script", Line: 5}, {Function: "f", Line: 3}}
+	if err, ok := err.(*lox.Error); !ok || err.Line != 3 || !slices.Equal(err.Trace, expectedTrace) {
+		t.Errorf("Expected error at line 3 with trace %v but got %#v", expectedTrace, err)
+	}
+}
+
+// TestNativeSharedError checks that on both backends, an error returned by a
+// native function is reported at the line of each call, even if the same error
+// is returned every time.
+func TestNativeSharedError(t *testing.T) {
+	errBadArg := &lox.Error{Msg: "Bad argument"}
+
+	globals := lox.NewGlobalEnvironment()
+	globals.DefineNative("fail", 0, func(args []lox.Value) (lox.Value, error) {
+		return lox.Value{}, errBadArg
+	})
+
+	// The tree-walking interpreter is the default backend
+	for _, opts := range [][]lox.Option{nil, {lox.WithBackend(vm.Backend{})}} {
+		in := lox.NewInterpreter(append(opts, lox.WithGlobals(globals), lox.WithStderr(&strings.Builder{}))...)
+
+		for _, line := range []int{1, 3} {
+			source := strings.Repeat("\n", line-1) + "fail();"
+			if err, ok := in.Run(source).(*lox.Error); !ok || err.Line != line {
+				t.Errorf("Expected runtime error on line %d, got '%v' instead", line, err)
+			}
+		}
+	}
+
+	if errBadArg.Line != 0 || errBadArg.Trace != nil {
+		t.Errorf("Expected the returned error to be unchanged, got %#v instead", errBadArg)
+	}
+}
+
+// Checks that a program produces the same output and exit status on both
+// backends.
+func testParity(t *testing.T, source string, opts ...lox.Option) {
+	t.Helper()
+
+	expectedOut, expectedStatus := testRun(t, source, opts...)
+	actualOut, actualStatus := testRun(t, source, append(opts, lox.WithBackend(vm.Backend{}))...)
+
+	if actualOut != expectedOut || actualStatus != expectedStatus {
+		t.Errorf("Program:\n%s\nExpected (%d):\n%s\nActual (%d):\n%s\n", source, expectedStatus, expectedOut, actualStatus, actualOut)
+	}
+}
+
+// Runs a program, returning its combined output and exit status.
+func testRun(t *testing.T, source string, opts ...lox.Option) (string, int) {
+	t.Helper()
+
+	var out strings.Builder
+
+	opts = append([]lox.Option{lox.WithStdout(&out), lox.WithStderr(&out)}, opts...)
+	status := lox.ExitStatus(lox.NewInterpreter(opts...).Run(source))
+
+	return out.String(), status
+}